@@ -0,0 +1,121 @@
+package databricksauthextension
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAzureIMDSEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+	defaultAzureResource     = "api://AzureADTokenExchange" // audience Entra ID issues for workload identity federation
+	azureIMDSAPIVersion      = "2018-02-01"
+)
+
+// azureIMDSResponse is the managed identity token response. IMDS encodes the numbers as strings.
+type azureIMDSResponse struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresOn   json.Number `json:"expires_on"` // unix seconds
+	ExpiresIn   json.Number `json:"expires_in"` // seconds
+}
+
+// AzureTokenProvider supplies the subject token from an Azure managed identity via the instance
+// metadata service (IMDS), for collectors running next to Azure Databricks.
+type AzureTokenProvider struct {
+	endpoint   string
+	resource   string
+	clientID   string
+	httpClient *http.Client
+	logger     *zap.Logger
+
+	cache cachedSubjectToken
+}
+
+// NewAzureTokenProvider returns a provider for cfg. A nil logger disables logging.
+func NewAzureTokenProvider(cfg AzureSourceConfig, logger *zap.Logger) *AzureTokenProvider {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	p := &AzureTokenProvider{
+		endpoint:   cfg.Endpoint,
+		resource:   cfg.Resource,
+		clientID:   cfg.ClientID,
+		httpClient: newMetadataHTTPClient(),
+		logger:     logger,
+	}
+	if p.endpoint == "" {
+		p.endpoint = defaultAzureIMDSEndpoint
+	}
+	if p.resource == "" {
+		p.resource = defaultAzureResource
+	}
+	return p
+}
+
+// SubjectToken returns a managed identity access token, caching it until near expiry.
+func (p *AzureTokenProvider) SubjectToken(ctx context.Context) (string, error) {
+	return p.cache.get(func() (string, time.Time, error) {
+		token, expiry, err := p.fetch(ctx)
+		if err != nil {
+			p.logger.Warn("Azure managed identity token request failed", zap.String("endpoint", p.endpoint), zap.Error(err))
+			return "", time.Time{}, err
+		}
+		p.logger.Debug("Fetched Azure managed identity token", zap.Time("expiry", expiry))
+		return token, expiry, nil
+	})
+}
+
+func (p *AzureTokenProvider) fetch(ctx context.Context) (string, time.Time, error) {
+	q := url.Values{}
+	q.Set("api-version", azureIMDSAPIVersion)
+	q.Set("resource", p.resource)
+	if p.clientID != "" {
+		q.Set("client_id", p.clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create IMDS request: %w", err)
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("IMDS request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to read IMDS response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("IMDS returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var out azureIMDSResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse IMDS response: %w", err)
+	}
+	if out.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("IMDS returned empty token")
+	}
+	return out.AccessToken, out.expiry(), nil
+}
+
+// expiry prefers the absolute expires_on, falling back to expires_in and then a conservative 5m.
+func (r *azureIMDSResponse) expiry() time.Time {
+	if secs, err := strconv.ParseInt(r.ExpiresOn.String(), 10, 64); err == nil && secs > 0 {
+		return time.Unix(secs, 0)
+	}
+	if secs, err := strconv.ParseInt(r.ExpiresIn.String(), 10, 64); err == nil && secs > 0 {
+		return time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	return time.Now().Add(5 * time.Minute)
+}
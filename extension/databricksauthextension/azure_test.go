@@ -0,0 +1,95 @@
+package databricksauthextension
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeIMDS answers managed identity token requests and records the query of the last one.
+func fakeIMDS(t *testing.T, gotQuery *url.Values, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("Metadata") != "true" {
+			http.Error(w, `{"error":"invalid_request","error_description":"Required metadata header not specified"}`, http.StatusBadRequest)
+			return
+		}
+		*gotQuery = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"azure-jwt","expires_on":"%d","expires_in":"3600","token_type":"Bearer"}`,
+			time.Now().Add(time.Hour).Unix())
+	}))
+}
+
+// TestAzureTokenProvider verifies the IMDS request and that the token is cached until near expiry.
+func TestAzureTokenProvider(t *testing.T) {
+	var gotQuery url.Values
+	var calls atomic.Int32
+	server := fakeIMDS(t, &gotQuery, &calls)
+	defer server.Close()
+
+	p := NewAzureTokenProvider(AzureSourceConfig{Endpoint: server.URL, ClientID: "uami-client-id"}, nil)
+	for range 2 {
+		token, err := p.SubjectToken(context.Background())
+		if err != nil {
+			t.Fatalf("SubjectToken: %v", err)
+		}
+		if token != "azure-jwt" {
+			t.Errorf("token = %q, want azure-jwt", token)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("IMDS calls = %d, want 1", n)
+	}
+	if gotQuery.Get("resource") != defaultAzureResource || gotQuery.Get("client_id") != "uami-client-id" || gotQuery.Get("api-version") != azureIMDSAPIVersion {
+		t.Errorf("query = %v, want default resource, client_id and api-version", gotQuery)
+	}
+}
+
+// TestAzureTokenProvider_Error verifies an IMDS error status is surfaced.
+func TestAzureTokenProvider_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"error":"invalid_request","error_description":"Identity not found"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	p := NewAzureTokenProvider(AzureSourceConfig{Endpoint: server.URL}, nil)
+	if _, err := p.SubjectToken(context.Background()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+// TestTokenCache_AzureSubjectToken verifies the managed identity token is exchanged through tokenCache.
+func TestTokenCache_AzureSubjectToken(t *testing.T) {
+	var gotQuery url.Values
+	var calls atomic.Int32
+	imds := fakeIMDS(t, &gotQuery, &calls)
+	defer imds.Close()
+
+	var gotSubject string
+	oidc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		gotSubject = r.PostForm.Get("subject_token")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"dbx-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer oidc.Close()
+
+	cache := newTestTokenCache(oidc.URL, NewAzureTokenProvider(AzureSourceConfig{Endpoint: imds.URL, Resource: "api://databricks"}, nil))
+	token, err := cache.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if token != "dbx-token" || gotSubject != "azure-jwt" {
+		t.Errorf("token = %q, subject_token = %q; want dbx-token, azure-jwt", token, gotSubject)
+	}
+	if gotQuery.Get("resource") != "api://databricks" {
+		t.Errorf("resource = %q, want api://databricks", gotQuery.Get("resource"))
+	}
+}
@@ -2,36 +2,511 @@ package databricksauthextension
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"go.opentelemetry.io/collector/config/configopaque"
 )
 
+// accountIDPattern matches Databricks account IDs.
+var accountIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
 // Config holds the configuration for the Databricks authenticator extension.
 type Config struct {
-	// Static mode (local dev). Mutually exclusive with federation fields.
+	// Static mode (local dev). Mutually exclusive with federation and M2M fields.
 	Token configopaque.String `mapstructure:"token"`
+	// File holding the static token, re-read when it changes so a rotated PAT is picked up
+	// without a restart. Mutually exclusive with token.
+	TokenFile string `mapstructure:"token_file"`
+
+	// Profile in ~/.databrickscfg (or DATABRICKS_CONFIG_FILE) supplying host, token, client_id and
+	// client_secret when they are not set here or in DATABRICKS_* environment variables.
+	// Default: DATABRICKS_CONFIG_PROFILE, then DEFAULT.
+	Profile string `mapstructure:"profile"`
 
 	// Federation mode (AWS→Databricks).
 	WorkspaceURL string        `mapstructure:"workspace_url"` // e.g. https://adb-xxx.cloud.databricks.com
 	SPClientID   string        `mapstructure:"sp_client_id"`  // Databricks SP OAuth app client ID
 	ExpiryBuffer time.Duration `mapstructure:"expiry_buffer"` // default: 5m
+
+	// Account-level token endpoint (<account_host>/oidc/accounts/<account_id>/v1/token) instead of
+	// the workspace one, for account-wide federation policies and account APIs.
+	AccountID   string `mapstructure:"account_id"`
+	AccountHost string `mapstructure:"account_host"` // default: https://accounts.cloud.databricks.com
+
+	// Token request parameters. Scopes are space-joined into scope (default: all-apis); audience,
+	// resource and requested_token_type are RFC 8693 token exchange parameters (federation only).
+	Scopes             []string `mapstructure:"scopes"`
+	Audience           string   `mapstructure:"audience"`
+	Resource           string   `mapstructure:"resource"`             // absolute URI of the target service
+	RequestedTokenType string   `mapstructure:"requested_token_type"` // urn:ietf:params:oauth:token-type:*
+
+	// OIDCDiscovery resolves the token endpoint from the authorization server metadata at Start and
+	// checks the required grant type is advertised. Default: false (use the well-known path).
+	OIDCDiscovery bool `mapstructure:"oidc_discovery"`
+
+	// AWS settings for federation mode.
+	AWS AWSConfig `mapstructure:"aws"`
+
+	// Where the federation subject token comes from. Default: AWS STS.
+	SubjectTokenSource SubjectTokenSourceConfig `mapstructure:"subject_token_source"`
+
+	// Shorthand for subject_token_source type file, e.g. a Kubernetes projected service account
+	// token whose issuer a Databricks federation policy trusts.
+	ServiceAccountTokenFile string `mapstructure:"service_account_token_file"`
+
+	// M2M mode (OAuth client_credentials). Uses sp_client_id + client_secret instead of AWS federation.
+	ClientSecret configopaque.String `mapstructure:"client_secret"`
+
+	// GraceMode keeps serving the cached token until its hard expiry when a refresh fails,
+	// so a short OIDC outage inside expiry_buffer does not drop telemetry. Default: false.
+	GraceMode bool `mapstructure:"grace_mode"`
+
+	// Retry policy for token exchange and STS calls.
+	Retry RetryConfig `mapstructure:"retry"`
+
+	// HTTP client settings for the OIDC token endpoint (timeout, proxy, TLS).
+	TokenEndpointClient TokenEndpointClientConfig `mapstructure:"token_endpoint_client"`
+
+	// Hosts the bearer token may be sent to. Default: the host of workspace_url. Entries are
+	// hostnames without scheme or port; a leading "*." matches any subdomain.
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+	// AllowInsecureHTTP permits sending the token over plain http or plaintext gRPC (local testing only). Default: false.
+	AllowInsecureHTTP bool `mapstructure:"allow_insecure_http"`
+
+	// Unity Catalog target tables, injected as X-Databricks-UC-Table-Name per OTLP signal.
+	UCTables UCTablesConfig `mapstructure:"uc_tables"`
+}
+
+// UCTablesConfig maps OTLP/HTTP signals to Unity Catalog tables so one otlphttp exporter can
+// serve all signals. Explicit per-signal names win over names derived from catalog/schema/table_prefix
+// (<catalog>.<schema>.<table_prefix>_otel_spans|metrics|logs).
+type UCTablesConfig struct {
+	Catalog     string `mapstructure:"catalog"`
+	Schema      string `mapstructure:"schema"`
+	TablePrefix string `mapstructure:"table_prefix"`
+
+	Traces  string `mapstructure:"traces"`
+	Metrics string `mapstructure:"metrics"`
+	Logs    string `mapstructure:"logs"`
+}
+
+// SubjectTokenSourceConfig selects a registered subject token source; only the block matching
+// Type is used.
+type SubjectTokenSourceConfig struct {
+	Type   string             `mapstructure:"type"` // aws_sts (default), file, env, exec, azure_managed_identity, gcp_metadata or github_actions
+	File   FileSourceConfig   `mapstructure:"file"`
+	Env    EnvSourceConfig    `mapstructure:"env"`
+	Exec   ExecSourceConfig   `mapstructure:"exec"`
+	Azure  AzureSourceConfig  `mapstructure:"azure"`
+	GCP    GCPSourceConfig    `mapstructure:"gcp"`
+	GitHub GitHubSourceConfig `mapstructure:"github"`
+}
+
+// FileSourceConfig reads the subject token from a file, re-read on every refresh.
+type FileSourceConfig struct {
+	Path string `mapstructure:"path"`
+}
+
+// EnvSourceConfig reads the subject token from an environment variable.
+type EnvSourceConfig struct {
+	Name string `mapstructure:"name"`
+}
+
+// ExecSourceConfig runs a command that prints the subject token, either bare or as JSON
+// {"token": "...", "expiration": "<RFC 3339>"}; JSON tokens are cached until near expiration.
+type ExecSourceConfig struct {
+	Command string            `mapstructure:"command"`
+	Args    []string          `mapstructure:"args"`
+	Env     map[string]string `mapstructure:"env"`     // added to the collector's environment
+	Timeout time.Duration     `mapstructure:"timeout"` // default: 30s
+}
+
+// AzureSourceConfig requests an Entra ID token for the VM or pod's managed identity from IMDS.
+type AzureSourceConfig struct {
+	Endpoint string `mapstructure:"endpoint"`  // default: http://169.254.169.254/metadata/identity/oauth2/token
+	Resource string `mapstructure:"resource"`  // token audience; default: api://AzureADTokenExchange
+	ClientID string `mapstructure:"client_id"` // user-assigned identity; default: the system-assigned identity
+}
+
+// GCPSourceConfig requests a Google-signed ID token for the instance's service account from the
+// GCE metadata server.
+type GCPSourceConfig struct {
+	Audience       string `mapstructure:"audience"`        // required; the federation policy's audience
+	MetadataHost   string `mapstructure:"metadata_host"`   // host[:port]; default: metadata.google.internal
+	ServiceAccount string `mapstructure:"service_account"` // default: the instance's default service account
+}
+
+// GitHubSourceConfig requests a GitHub Actions OIDC token using the job's
+// ACTIONS_ID_TOKEN_REQUEST_URL / ACTIONS_ID_TOKEN_REQUEST_TOKEN.
+type GitHubSourceConfig struct {
+	Audience string `mapstructure:"audience"` // default: GitHub's default (the repository owner URL)
+}
+
+// AWSConfig customises how the AWS web identity token is obtained in federation mode.
+type AWSConfig struct {
+	Region           string   `mapstructure:"region"`            // default: from the environment / shared config
+	STSEndpoint      string   `mapstructure:"sts_endpoint"`      // e.g. a VPC or FIPS endpoint, or a local stand-in
+	Profile          string   `mapstructure:"profile"`           // shared config profile
+	Audience         []string `mapstructure:"audience"`          // default: [AwsTokenExchange]
+	SigningAlgorithm string   `mapstructure:"signing_algorithm"` // RS256 (default) or ES384
+
+	// Role to assume before GetWebIdentityToken, e.g. a workload-account role trusted by the
+	// Databricks federation policy when the collector runs under a shared platform role.
+	AssumeRoleARN string        `mapstructure:"assume_role_arn"`
+	ExternalID    string        `mapstructure:"external_id"`
+	SessionName   string        `mapstructure:"session_name"` // default: otel-databricksauth
+	Duration      time.Duration `mapstructure:"duration"`     // 15m–1h (STS role chaining limit); default: 15m
+}
+
+// TokenEndpointClientConfig configures the HTTP client used for token exchanges. Field names
+// follow confighttp.ClientConfig / configtls.ClientConfig so exporter settings can be reused.
+type TokenEndpointClientConfig struct {
+	Timeout  time.Duration   `mapstructure:"timeout"`   // default: 30s
+	ProxyURL string          `mapstructure:"proxy_url"` // default: HTTPS_PROXY/NO_PROXY from the environment
+	TLS      TLSClientConfig `mapstructure:"tls"`
+}
+
+// TLSClientConfig holds TLS settings for the token endpoint client.
+type TLSClientConfig struct {
+	CAFile                   string `mapstructure:"ca_file"`                      // PEM bundle of trusted roots
+	IncludeSystemCACertsPool bool   `mapstructure:"include_system_ca_certs_pool"` // also trust system roots when ca_file is set
+	CertFile                 string `mapstructure:"cert_file"`                    // client certificate for mTLS
+	KeyFile                  string `mapstructure:"key_file"`                     // client key for mTLS
+	InsecureSkipVerify       bool   `mapstructure:"insecure_skip_verify"`
+	ServerName               string `mapstructure:"server_name_override"`
+}
+
+// RetryConfig controls exponential backoff for retriable token acquisition failures
+// (OIDC 429/5xx and connection errors, STS throttling).
+type RetryConfig struct {
+	MaxAttempts     int           `mapstructure:"max_attempts"`     // default: 3; 1 disables retries
+	InitialInterval time.Duration `mapstructure:"initial_interval"` // default: 500ms
+	MaxInterval     time.Duration `mapstructure:"max_interval"`     // default: 10s
 }
 
+// Validate checks the configuration after applying the Databricks unified auth fallbacks (see
+// resolve); errors name the environment variables or profile that supplied values.
 func (c *Config) Validate() error {
-	hasStatic := c.Token != ""
-	hasFed := c.SPClientID != ""
+	resolved, sources, err := c.resolve()
+	if err != nil {
+		return err
+	}
+	if err := resolved.validate(); err != nil {
+		if from := sources.String(); from != "" {
+			return fmt.Errorf("%w (%s)", err, from)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	hasStatic := c.Token != "" || c.TokenFile != ""
+	hasClientID := c.SPClientID != ""
+	hasSecret := c.ClientSecret != ""
 	switch {
-	case !hasStatic && !hasFed:
-		return errors.New("either token or sp_client_id must be configured")
-	case hasStatic && hasFed:
-		return errors.New("token and sp_client_id are mutually exclusive")
-	case hasFed && c.WorkspaceURL == "":
+	case !hasStatic && !hasClientID:
+		return errors.New("either token, token_file or sp_client_id must be configured")
+	case c.Token != "" && c.TokenFile != "":
+		return errors.New("token and token_file are mutually exclusive")
+	case hasStatic && hasClientID:
+		return errors.New("token/token_file and sp_client_id are mutually exclusive")
+	case hasStatic && hasSecret:
+		return errors.New("token/token_file and client_secret are mutually exclusive")
+	case hasSecret && !hasClientID:
+		return errors.New("sp_client_id is required when client_secret is set")
+	case hasClientID && c.WorkspaceURL == "" && c.AccountID == "":
 		return errors.New("workspace_url is required when sp_client_id is set")
+	case c.AccountID != "" && !hasClientID:
+		return errors.New("account_id requires sp_client_id")
+	case c.AccountHost != "" && c.AccountID == "":
+		return errors.New("account_host requires account_id")
+	case c.OIDCDiscovery && !hasClientID:
+		return errors.New("oidc_discovery requires sp_client_id")
+	case len(c.Scopes) > 0 && !hasClientID:
+		return errors.New("scopes requires sp_client_id")
+	case (c.Audience != "" || c.Resource != "" || c.RequestedTokenType != "") && (!hasClientID || hasSecret):
+		return errors.New("audience, resource and requested_token_type apply to token exchange and require sp_client_id without client_secret")
+	case c.AccountID != "" && c.WorkspaceURL == "" && len(c.AllowedHosts) == 0:
+		return errors.New("allowed_hosts is required when account_id is set without workspace_url")
+	case hasStatic && c.WorkspaceURL == "" && len(c.AllowedHosts) == 0:
+		return errors.New("workspace_url or allowed_hosts is required when token or token_file is set, so the token is only sent to known hosts")
+	case c.ServiceAccountTokenFile != "" && c.SubjectTokenSource.Type != "":
+		return errors.New("service_account_token_file and subject_token_source are mutually exclusive")
+	case (c.ServiceAccountTokenFile != "" || c.SubjectTokenSource.Type != "") && (!hasClientID || hasSecret):
+		return errors.New("subject_token_source and service_account_token_file require sp_client_id and cannot be combined with client_secret")
+	}
+	for _, h := range c.AllowedHosts {
+		if h == "" || strings.ContainsAny(h, "/:") {
+			return fmt.Errorf("allowed_hosts entry %q must be a bare hostname", h)
+		}
+	}
+	if c.WorkspaceURL != "" {
+		if u, err := url.Parse(c.WorkspaceURL); err != nil || u.Host == "" {
+			return fmt.Errorf("workspace_url %q must be an absolute URL", c.WorkspaceURL)
+		}
+	}
+	for _, scope := range c.Scopes {
+		if scope == "" || strings.ContainsAny(scope, " \t\n\"\\") {
+			return fmt.Errorf("scopes entry %q must be a non-empty scope token without whitespace or quotes", scope)
+		}
+	}
+	if c.Resource != "" {
+		if u, err := url.Parse(c.Resource); err != nil || !u.IsAbs() || u.Fragment != "" {
+			return fmt.Errorf("resource %q must be an absolute URI without a fragment", c.Resource)
+		}
+	}
+	if c.RequestedTokenType != "" && !strings.HasPrefix(c.RequestedTokenType, tokenTypePrefix) {
+		return fmt.Errorf("requested_token_type %q must be a token type URI (%s...)", c.RequestedTokenType, tokenTypePrefix)
+	}
+	if c.AccountID != "" && !accountIDPattern.MatchString(c.AccountID) {
+		return fmt.Errorf("account_id %q must be a Databricks account ID (UUID)", c.AccountID)
+	}
+	if c.AccountHost != "" {
+		if u, err := url.Parse(c.AccountHost); err != nil || u.Host == "" {
+			return fmt.Errorf("account_host %q must be an absolute URL", c.AccountHost)
+		}
+	}
+	if err := c.UCTables.Validate(); err != nil {
+		return err
+	}
+	if err := c.TokenEndpointClient.Validate(); err != nil {
+		return err
+	}
+	if err := c.AWS.Validate(); err != nil {
+		return err
+	}
+	if err := c.SubjectTokenSource.Validate(); err != nil {
+		return err
+	}
+	return c.Retry.Validate()
+}
+
+func (s *SubjectTokenSourceConfig) Validate() error {
+	if s.Type == "" {
+		return nil
+	}
+	reg, ok := subjectTokenSources[s.Type]
+	if !ok {
+		return fmt.Errorf("subject_token_source.type %q must be one of %s", s.Type, strings.Join(registeredSubjectTokenSources(), ", "))
+	}
+	if reg.validate == nil {
+		return nil
+	}
+	return reg.validate(s)
+}
+
+// subjectTokenSource returns the effective subject token source, resolving the default type and
+// the service_account_token_file shorthand.
+func (c *Config) subjectTokenSource() SubjectTokenSourceConfig {
+	src := c.SubjectTokenSource
+	switch {
+	case c.ServiceAccountTokenFile != "":
+		src.Type = sourceFile
+		src.File.Path = c.ServiceAccountTokenFile
+	case src.Type == "":
+		src.Type = sourceAWSSTS
+	}
+	return src
+}
+
+func (u *UCTablesConfig) Validate() error {
+	set := 0
+	for _, v := range []string{u.Catalog, u.Schema, u.TablePrefix} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 0 && set != 3 {
+		return errors.New("uc_tables.catalog, uc_tables.schema and uc_tables.table_prefix must be set together")
+	}
+	return nil
+}
+
+// tableForPath returns the UC table for an OTLP/HTTP request path, or "" if none is configured.
+func (u *UCTablesConfig) tableForPath(path string) string {
+	var explicit, suffix string
+	switch {
+	case strings.HasSuffix(path, "/v1/traces"):
+		explicit, suffix = u.Traces, "otel_spans"
+	case strings.HasSuffix(path, "/v1/metrics"):
+		explicit, suffix = u.Metrics, "otel_metrics"
+	case strings.HasSuffix(path, "/v1/logs"):
+		explicit, suffix = u.Logs, "otel_logs"
+	default:
+		return ""
+	}
+	if explicit != "" {
+		return explicit
+	}
+	if u.TablePrefix == "" {
+		return ""
+	}
+	return u.Catalog + "." + u.Schema + "." + u.TablePrefix + "_" + suffix
+}
+
+func (a *AWSConfig) Validate() error {
+	switch a.SigningAlgorithm {
+	case "", "RS256", "ES384":
+	default:
+		return fmt.Errorf("aws.signing_algorithm %q must be RS256 or ES384", a.SigningAlgorithm)
+	}
+	if a.STSEndpoint != "" {
+		if u, err := url.Parse(a.STSEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("aws.sts_endpoint %q must be an absolute URL", a.STSEndpoint)
+		}
+	}
+	for _, aud := range a.Audience {
+		if aud == "" {
+			return errors.New("aws.audience entries must not be empty")
+		}
+	}
+	if a.AssumeRoleARN == "" {
+		if a.ExternalID != "" || a.SessionName != "" || a.Duration != 0 {
+			return errors.New("aws.external_id, aws.session_name and aws.duration require aws.assume_role_arn")
+		}
+		return nil
+	}
+	if !strings.HasPrefix(a.AssumeRoleARN, "arn:") || !strings.Contains(a.AssumeRoleARN, ":role/") {
+		return fmt.Errorf("aws.assume_role_arn %q must be an IAM role ARN", a.AssumeRoleARN)
+	}
+	// The ambient credentials are usually a role session themselves (task or pod role), and STS
+	// caps chained role sessions at 1h regardless of the role's maximum session duration.
+	if a.Duration != 0 && (a.Duration < 15*time.Minute || a.Duration > time.Hour) {
+		return errors.New("aws.duration must be between 15m and 1h (the STS limit for role chaining)")
 	}
 	return nil
 }
 
+// stsOptions returns the GetWebIdentityToken request options.
+func (a *AWSConfig) stsOptions() STSTokenProviderOptions {
+	return STSTokenProviderOptions{
+		Audience:         a.Audience,
+		SigningAlgorithm: a.SigningAlgorithm,
+		Endpoint:         a.STSEndpoint,
+		AssumeRoleARN:    a.AssumeRoleARN,
+		ExternalID:       a.ExternalID,
+		SessionName:      a.SessionName,
+		Duration:         a.Duration,
+	}
+}
+
+// loadOptions returns the AWS SDK config loading overrides.
+func (a *AWSConfig) loadOptions() []func(*awsconfig.LoadOptions) error {
+	var opts []func(*awsconfig.LoadOptions) error
+	if a.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(a.Region))
+	}
+	if a.Profile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(a.Profile))
+	}
+	return opts
+}
+
+func (t *TokenEndpointClientConfig) Validate() error {
+	if t.Timeout < 0 {
+		return errors.New("token_endpoint_client.timeout must not be negative")
+	}
+	if t.ProxyURL != "" {
+		if u, err := url.Parse(t.ProxyURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("token_endpoint_client.proxy_url %q must be an absolute URL", t.ProxyURL)
+		}
+	}
+	if (t.TLS.CertFile == "") != (t.TLS.KeyFile == "") {
+		return errors.New("token_endpoint_client.tls.cert_file and key_file must be set together")
+	}
+	return nil
+}
+
+func (r *RetryConfig) Validate() error {
+	switch {
+	case r.MaxAttempts < 0:
+		return errors.New("retry.max_attempts must not be negative")
+	case r.InitialInterval < 0 || r.MaxInterval < 0:
+		return errors.New("retry intervals must not be negative")
+	case r.MaxInterval > 0 && r.InitialInterval > r.MaxInterval:
+		return errors.New("retry.initial_interval must not exceed retry.max_interval")
+	}
+	return nil
+}
+
+// policy resolves the configured values against defaults.
+func (r *RetryConfig) policy() retryPolicy {
+	p := retryPolicy{
+		maxAttempts:     3,
+		initialInterval: 500 * time.Millisecond,
+		maxInterval:     10 * time.Second,
+	}
+	if r.MaxAttempts > 0 {
+		p.maxAttempts = r.MaxAttempts
+	}
+	if r.InitialInterval > 0 {
+		p.initialInterval = r.InitialInterval
+	}
+	if r.MaxInterval > 0 {
+		p.maxInterval = r.MaxInterval
+	}
+	return p
+}
+
+// allowedHosts returns the effective token destination allowlist: allowed_hosts if set, otherwise
+// the workspace_url host. Validate ensures it is non-empty; an empty result means no host restriction.
+func (c *Config) allowedHosts() []string {
+	if len(c.AllowedHosts) > 0 {
+		return c.AllowedHosts
+	}
+	if u, err := url.Parse(c.WorkspaceURL); err == nil && u.Hostname() != "" {
+		return []string{u.Hostname()}
+	}
+	return nil
+}
+
+// tokenEndpoint returns the OIDC token URL: the account endpoint when account_id is set,
+// otherwise the workspace endpoint.
+func (c *Config) tokenEndpoint() string {
+	if c.AccountID == "" {
+		return c.WorkspaceURL + oidcTokenEndpoint
+	}
+	host := c.AccountHost
+	if host == "" {
+		host = defaultAccountHost
+	}
+	return strings.TrimSuffix(host, "/") + fmt.Sprintf(accountTokenEndpoint, c.AccountID)
+}
+
+// requestParams returns the configured token request parameters, overriding the defaults.
+func (c *Config) requestParams() url.Values {
+	params := url.Values{}
+	if len(c.Scopes) > 0 {
+		params.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if c.Audience != "" {
+		params.Set("audience", c.Audience)
+	}
+	if c.Resource != "" {
+		params.Set("resource", c.Resource)
+	}
+	if c.RequestedTokenType != "" {
+		params.Set("requested_token_type", c.RequestedTokenType)
+	}
+	return params
+}
+
+// discoveryURL returns the authorization server metadata URL for the configured token endpoint.
+func (c *Config) discoveryURL() string {
+	return strings.TrimSuffix(c.tokenEndpoint(), "/v1/token") + oidcDiscoveryPath
+}
+
+// isM2M reports whether the config selects OAuth M2M (client_credentials) mode.
+func (c *Config) isM2M() bool {
+	return c.SPClientID != "" && c.ClientSecret != ""
+}
+
 func (c *Config) expiryBufferOrDefault() time.Duration {
 	if c.ExpiryBuffer > 0 {
 		return c.ExpiryBuffer
@@ -1,6 +1,7 @@
 package databricksauthextension
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -20,14 +21,39 @@ func TestConfig_Validate(t *testing.T) {
 		},
 		{
 			name:    "static token only",
-			cfg:     Config{Token: configopaque.String("my-token")},
+			cfg:     Config{Token: configopaque.String("my-token"), WorkspaceURL: "https://adb-123.cloud.databricks.com"},
+			wantErr: false,
+		},
+		{
+			name:    "static token with allowed_hosts",
+			cfg:     Config{Token: configopaque.String("my-token"), AllowedHosts: []string{"otlp.example.com"}},
 			wantErr: false,
 		},
+		{
+			name:    "static token without workspace_url or allowed_hosts",
+			cfg:     Config{Token: configopaque.String("my-token")},
+			wantErr: true,
+		},
 		{
 			name:    "sp_client_id and workspace_url",
 			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com"},
 			wantErr: false,
 		},
+		{
+			name:    "token_file only",
+			cfg:     Config{TokenFile: "/var/run/secrets/databricks/token", WorkspaceURL: "https://adb-123.cloud.databricks.com"},
+			wantErr: false,
+		},
+		{
+			name:    "both token and token_file",
+			cfg:     Config{Token: "tok", TokenFile: "/var/run/secrets/databricks/token"},
+			wantErr: true,
+		},
+		{
+			name:    "token_file and sp_client_id",
+			cfg:     Config{TokenFile: "/var/run/secrets/databricks/token", SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com"},
+			wantErr: true,
+		},
 		{
 			name:    "both token and sp_client_id",
 			cfg:     Config{Token: "tok", SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com"},
@@ -38,6 +64,286 @@ func TestConfig_Validate(t *testing.T) {
 			cfg:     Config{SPClientID: "client-id"},
 			wantErr: true,
 		},
+		{
+			name:    "sp_client_id, client_secret and workspace_url",
+			cfg:     Config{SPClientID: "client-id", ClientSecret: "secret", WorkspaceURL: "https://adb-123.cloud.databricks.com"},
+			wantErr: false,
+		},
+		{
+			name:    "client_secret without sp_client_id",
+			cfg:     Config{ClientSecret: "secret", WorkspaceURL: "https://adb-123.cloud.databricks.com"},
+			wantErr: true,
+		},
+		{
+			name:    "both token and client_secret",
+			cfg:     Config{Token: "tok", ClientSecret: "secret"},
+			wantErr: true,
+		},
+		{
+			name:    "client_secret without workspace_url",
+			cfg:     Config{SPClientID: "client-id", ClientSecret: "secret"},
+			wantErr: true,
+		},
+		{
+			name:    "negative retry.max_attempts",
+			cfg:     Config{Token: "tok", WorkspaceURL: "https://adb-123.cloud.databricks.com", Retry: RetryConfig{MaxAttempts: -1}},
+			wantErr: true,
+		},
+		{
+			name:    "retry.initial_interval exceeds max_interval",
+			cfg:     Config{Token: "tok", WorkspaceURL: "https://adb-123.cloud.databricks.com", Retry: RetryConfig{InitialInterval: 2 * time.Second, MaxInterval: time.Second}},
+			wantErr: true,
+		},
+		{
+			name:    "valid retry config",
+			cfg:     Config{Token: "tok", WorkspaceURL: "https://adb-123.cloud.databricks.com", Retry: RetryConfig{MaxAttempts: 5, InitialInterval: time.Second, MaxInterval: 30 * time.Second}},
+			wantErr: false,
+		},
+		{
+			name:    "uc_tables catalog/schema/table_prefix",
+			cfg:     Config{Token: "tok", WorkspaceURL: "https://adb-123.cloud.databricks.com", UCTables: UCTablesConfig{Catalog: "main", Schema: "otel", TablePrefix: "prod"}},
+			wantErr: false,
+		},
+		{
+			name:    "uc_tables partial catalog/schema/table_prefix",
+			cfg:     Config{Token: "tok", WorkspaceURL: "https://adb-123.cloud.databricks.com", UCTables: UCTablesConfig{Catalog: "main", TablePrefix: "prod"}},
+			wantErr: true,
+		},
+		{
+			name:    "uc_tables explicit tables only",
+			cfg:     Config{Token: "tok", WorkspaceURL: "https://adb-123.cloud.databricks.com", UCTables: UCTablesConfig{Traces: "main.otel.spans"}},
+			wantErr: false,
+		},
+		{
+			name:    "allowed_hosts with scheme",
+			cfg:     Config{Token: "tok", AllowedHosts: []string{"https://adb-123.cloud.databricks.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "allowed_hosts bare hostnames",
+			cfg:     Config{Token: "tok", AllowedHosts: []string{"adb-123.cloud.databricks.com", "*.cloud.databricks.com"}},
+			wantErr: false,
+		},
+		{
+			name:    "relative workspace_url",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "adb-123.cloud.databricks.com"},
+			wantErr: true,
+		},
+		{
+			name:    "token_endpoint_client cert_file without key_file",
+			cfg:     Config{Token: "tok", WorkspaceURL: "https://adb-123.cloud.databricks.com", TokenEndpointClient: TokenEndpointClientConfig{TLS: TLSClientConfig{CertFile: "client.pem"}}},
+			wantErr: true,
+		},
+		{
+			name:    "token_endpoint_client relative proxy_url",
+			cfg:     Config{Token: "tok", WorkspaceURL: "https://adb-123.cloud.databricks.com", TokenEndpointClient: TokenEndpointClientConfig{ProxyURL: "proxy:3128"}},
+			wantErr: true,
+		},
+		{
+			name:    "token_endpoint_client valid",
+			cfg:     Config{Token: "tok", WorkspaceURL: "https://adb-123.cloud.databricks.com", TokenEndpointClient: TokenEndpointClientConfig{Timeout: 10 * time.Second, ProxyURL: "http://proxy.internal:3128"}},
+			wantErr: false,
+		},
+		{
+			name:    "aws options",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AWS: AWSConfig{Region: "eu-west-1", STSEndpoint: "https://sts.eu-west-1.amazonaws.com", Profile: "platform", Audience: []string{"AwsTokenExchange"}, SigningAlgorithm: "ES384"}},
+			wantErr: false,
+		},
+		{
+			name:    "aws unsupported signing_algorithm",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AWS: AWSConfig{SigningAlgorithm: "HS256"}},
+			wantErr: true,
+		},
+		{
+			name:    "aws relative sts_endpoint",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AWS: AWSConfig{STSEndpoint: "sts.amazonaws.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "aws empty audience entry",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AWS: AWSConfig{Audience: []string{""}}},
+			wantErr: true,
+		},
+		{
+			name:    "aws assume role",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AWS: AWSConfig{AssumeRoleARN: "arn:aws:iam::123456789012:role/otel-databricks", ExternalID: "ext", SessionName: "collector", Duration: time.Hour}},
+			wantErr: false,
+		},
+		{
+			name:    "aws assume role options without arn",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AWS: AWSConfig{ExternalID: "ext"}},
+			wantErr: true,
+		},
+		{
+			name:    "aws assume_role_arn not a role arn",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AWS: AWSConfig{AssumeRoleARN: "otel-databricks"}},
+			wantErr: true,
+		},
+		{
+			name:    "aws assume role duration out of range",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AWS: AWSConfig{AssumeRoleARN: "arn:aws:iam::123456789012:role/otel-databricks", Duration: time.Minute}},
+			wantErr: true,
+		},
+		{
+			name:    "aws assume role duration beyond role chaining limit",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AWS: AWSConfig{AssumeRoleARN: "arn:aws:iam::123456789012:role/otel-databricks", Duration: 2 * time.Hour}},
+			wantErr: true,
+		},
+		{
+			name:    "service_account_token_file federation",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", ServiceAccountTokenFile: "/var/run/secrets/databricks/token"},
+			wantErr: false,
+		},
+		{
+			name:    "service_account_token_file with client_secret",
+			cfg:     Config{SPClientID: "client-id", ClientSecret: "secret", WorkspaceURL: "https://adb-123.cloud.databricks.com", ServiceAccountTokenFile: "/var/run/secrets/databricks/token"},
+			wantErr: true,
+		},
+		{
+			name:    "service_account_token_file with static token",
+			cfg:     Config{Token: "tok", ServiceAccountTokenFile: "/var/run/secrets/databricks/token"},
+			wantErr: true,
+		},
+		{
+			name:    "subject_token_source env",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", SubjectTokenSource: SubjectTokenSourceConfig{Type: "env", Env: EnvSourceConfig{Name: "SUBJECT_TOKEN"}}},
+			wantErr: false,
+		},
+		{
+			name:    "subject_token_source unknown type",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", SubjectTokenSource: SubjectTokenSourceConfig{Type: "kerberos"}},
+			wantErr: true,
+		},
+		{
+			name:    "subject_token_source file without path",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", SubjectTokenSource: SubjectTokenSourceConfig{Type: "file"}},
+			wantErr: true,
+		},
+		{
+			name:    "subject_token_source exec without command",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", SubjectTokenSource: SubjectTokenSourceConfig{Type: "exec"}},
+			wantErr: true,
+		},
+		{
+			name:    "subject_token_source with service_account_token_file",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", ServiceAccountTokenFile: "/token", SubjectTokenSource: SubjectTokenSourceConfig{Type: "aws_sts"}},
+			wantErr: true,
+		},
+		{
+			name:    "subject_token_source in M2M mode",
+			cfg:     Config{SPClientID: "client-id", ClientSecret: "secret", WorkspaceURL: "https://adb-123.cloud.databricks.com", SubjectTokenSource: SubjectTokenSourceConfig{Type: "aws_sts"}},
+			wantErr: true,
+		},
+		{
+			name:    "subject_token_source azure_managed_identity",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.azuredatabricks.net", SubjectTokenSource: SubjectTokenSourceConfig{Type: "azure_managed_identity", Azure: AzureSourceConfig{Endpoint: "http://127.0.0.1:8080/token", ClientID: "uami"}}},
+			wantErr: false,
+		},
+		{
+			name:    "subject_token_source azure relative endpoint",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.azuredatabricks.net", SubjectTokenSource: SubjectTokenSourceConfig{Type: "azure_managed_identity", Azure: AzureSourceConfig{Endpoint: "metadata/identity"}}},
+			wantErr: true,
+		},
+		{
+			name:    "subject_token_source gcp_metadata",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://123.4.gcp.databricks.com", SubjectTokenSource: SubjectTokenSourceConfig{Type: "gcp_metadata", GCP: GCPSourceConfig{Audience: "databricks", MetadataHost: "127.0.0.1:8080"}}},
+			wantErr: false,
+		},
+		{
+			name:    "subject_token_source gcp_metadata without audience",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://123.4.gcp.databricks.com", SubjectTokenSource: SubjectTokenSourceConfig{Type: "gcp_metadata"}},
+			wantErr: true,
+		},
+		{
+			name:    "subject_token_source gcp_metadata host with scheme",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://123.4.gcp.databricks.com", SubjectTokenSource: SubjectTokenSourceConfig{Type: "gcp_metadata", GCP: GCPSourceConfig{Audience: "databricks", MetadataHost: "http://metadata"}}},
+			wantErr: true,
+		},
+		{
+			name:    "subject_token_source github_actions",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", SubjectTokenSource: SubjectTokenSourceConfig{Type: "github_actions", GitHub: GitHubSourceConfig{Audience: "databricks"}}},
+			wantErr: false,
+		},
+		{
+			name:    "account_id with workspace_url",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AccountID: "0d26daa6-5e44-4c97-a497-ef015f91254a"},
+			wantErr: false,
+		},
+		{
+			name:    "account_id and account_host with allowed_hosts only",
+			cfg:     Config{SPClientID: "client-id", AccountID: "0d26daa6-5e44-4c97-a497-ef015f91254a", AccountHost: "https://accounts.azuredatabricks.net", AllowedHosts: []string{"adb-123.azuredatabricks.net"}},
+			wantErr: false,
+		},
+		{
+			name:    "account_id without workspace_url or allowed_hosts",
+			cfg:     Config{SPClientID: "client-id", AccountID: "0d26daa6-5e44-4c97-a497-ef015f91254a"},
+			wantErr: true,
+		},
+		{
+			name:    "account_id in static mode",
+			cfg:     Config{Token: "tok", AccountID: "0d26daa6-5e44-4c97-a497-ef015f91254a"},
+			wantErr: true,
+		},
+		{
+			name:    "account_host without account_id",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AccountHost: "https://accounts.cloud.databricks.com"},
+			wantErr: true,
+		},
+		{
+			name:    "account_id not a UUID",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AccountID: "../workspace"},
+			wantErr: true,
+		},
+		{
+			name:    "account_host relative",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", AccountID: "0d26daa6-5e44-4c97-a497-ef015f91254a", AccountHost: "accounts.cloud.databricks.com"},
+			wantErr: true,
+		},
+		{
+			name:    "oidc_discovery in static mode",
+			cfg:     Config{Token: "tok", OIDCDiscovery: true},
+			wantErr: true,
+		},
+		{
+			name:    "token exchange parameters",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", Scopes: []string{"sql", "offline_access"}, Audience: "databricks", Resource: "https://adb-123.cloud.databricks.com", RequestedTokenType: "urn:ietf:params:oauth:token-type:access_token"},
+			wantErr: false,
+		},
+		{
+			name:    "scopes in m2m mode",
+			cfg:     Config{SPClientID: "client-id", ClientSecret: "secret", WorkspaceURL: "https://adb-123.cloud.databricks.com", Scopes: []string{"sql"}},
+			wantErr: false,
+		},
+		{
+			name:    "scopes in static mode",
+			cfg:     Config{Token: "tok", Scopes: []string{"sql"}},
+			wantErr: true,
+		},
+		{
+			name:    "scopes entry with whitespace",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", Scopes: []string{"sql all-apis"}},
+			wantErr: true,
+		},
+		{
+			name:    "scopes empty entry",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", Scopes: []string{""}},
+			wantErr: true,
+		},
+		{
+			name:    "audience in m2m mode",
+			cfg:     Config{SPClientID: "client-id", ClientSecret: "secret", WorkspaceURL: "https://adb-123.cloud.databricks.com", Audience: "databricks"},
+			wantErr: true,
+		},
+		{
+			name:    "resource not absolute",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", Resource: "/api"},
+			wantErr: true,
+		},
+		{
+			name:    "requested_token_type not a token type URI",
+			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com", RequestedTokenType: "access_token"},
+			wantErr: true,
+		},
 		{
 			name:    "sp_client_id with empty expiry_buffer uses default",
 			cfg:     Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com"},
@@ -70,3 +376,94 @@ func TestConfig_expiryBufferOrDefault(t *testing.T) {
 		}
 	})
 }
+
+func TestRetryConfig_policy(t *testing.T) {
+	t.Run("returns defaults when zero", func(t *testing.T) {
+		got := (&RetryConfig{}).policy()
+		want := retryPolicy{maxAttempts: 3, initialInterval: 500 * time.Millisecond, maxInterval: 10 * time.Second}
+		if got != want {
+			t.Errorf("policy() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("returns configured values when set", func(t *testing.T) {
+		got := (&RetryConfig{MaxAttempts: 1, InitialInterval: time.Second, MaxInterval: time.Minute}).policy()
+		want := retryPolicy{maxAttempts: 1, initialInterval: time.Second, maxInterval: time.Minute}
+		if got != want {
+			t.Errorf("policy() = %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestUCTablesConfig_tableForPath(t *testing.T) {
+	derived := UCTablesConfig{Catalog: "main", Schema: "otel", TablePrefix: "prod", Logs: "main.otel.custom_logs"}
+	tests := []struct {
+		name string
+		cfg  UCTablesConfig
+		path string
+		want string
+	}{
+		{name: "derived traces", cfg: derived, path: "/api/2.0/otel/v1/traces", want: "main.otel.prod_otel_spans"},
+		{name: "derived metrics", cfg: derived, path: "/api/2.0/otel/v1/metrics", want: "main.otel.prod_otel_metrics"},
+		{name: "explicit logs win", cfg: derived, path: "/api/2.0/otel/v1/logs", want: "main.otel.custom_logs"},
+		{name: "unknown path", cfg: derived, path: "/api/2.0/otel/v1/profiles", want: ""},
+		{name: "explicit only, unset signal", cfg: UCTablesConfig{Traces: "a.b.c"}, path: "/v1/metrics", want: ""},
+		{name: "empty config", cfg: UCTablesConfig{}, path: "/v1/traces", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.tableForPath(tt.path); got != tt.want {
+				t.Errorf("tableForPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_allowedHosts(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{name: "defaults to workspace_url host", cfg: Config{WorkspaceURL: "https://adb-123.cloud.databricks.com:443"}, want: []string{"adb-123.cloud.databricks.com"}},
+		{name: "explicit allowed_hosts win", cfg: Config{WorkspaceURL: "https://adb-123.cloud.databricks.com", AllowedHosts: []string{"a.example.com"}}, want: []string{"a.example.com"}},
+		{name: "unrestricted without workspace_url", cfg: Config{Token: "tok"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.allowedHosts(); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("allowedHosts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_tokenEndpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{name: "workspace", cfg: Config{WorkspaceURL: "https://adb-123.cloud.databricks.com"}, want: "https://adb-123.cloud.databricks.com/oidc/v1/token"},
+		{name: "account default host", cfg: Config{WorkspaceURL: "https://adb-123.cloud.databricks.com", AccountID: "0d26daa6-5e44-4c97-a497-ef015f91254a"}, want: "https://accounts.cloud.databricks.com/oidc/accounts/0d26daa6-5e44-4c97-a497-ef015f91254a/v1/token"},
+		{name: "account custom host", cfg: Config{AccountID: "0d26daa6-5e44-4c97-a497-ef015f91254a", AccountHost: "https://accounts.azuredatabricks.net/"}, want: "https://accounts.azuredatabricks.net/oidc/accounts/0d26daa6-5e44-4c97-a497-ef015f91254a/v1/token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.tokenEndpoint(); got != tt.want {
+				t.Errorf("tokenEndpoint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_discoveryURL(t *testing.T) {
+	workspace := Config{WorkspaceURL: "https://adb-123.cloud.databricks.com"}
+	if got, want := workspace.discoveryURL(), "https://adb-123.cloud.databricks.com/oidc/.well-known/oauth-authorization-server"; got != want {
+		t.Errorf("discoveryURL() = %q, want %q", got, want)
+	}
+	account := Config{AccountID: "0d26daa6-5e44-4c97-a497-ef015f91254a"}
+	if got, want := account.discoveryURL(), "https://accounts.cloud.databricks.com/oidc/accounts/0d26daa6-5e44-4c97-a497-ef015f91254a/.well-known/oauth-authorization-server"; got != want {
+		t.Errorf("discoveryURL() = %q, want %q", got, want)
+	}
+}
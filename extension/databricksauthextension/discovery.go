@@ -0,0 +1,84 @@
+package databricksauthextension
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// oidcDiscoveryPath is appended to the OIDC base (<workspace>/oidc or <account_host>/oidc/accounts/<id>).
+const oidcDiscoveryPath = "/.well-known/oauth-authorization-server"
+
+// oidcMetadata is the subset of RFC 8414 authorization server metadata the extension uses.
+type oidcMetadata struct {
+	Issuer              string   `json:"issuer"`
+	TokenEndpoint       string   `json:"token_endpoint"`
+	GrantTypesSupported []string `json:"grant_types_supported"`
+}
+
+// discoverOIDC fetches the authorization server metadata at discoveryURL and checks it advertises
+// grantType, so a workspace without federation or M2M support fails at Start with a clear message
+// rather than on the first export. Transient failures are retried according to policy.
+func discoverOIDC(ctx context.Context, client *http.Client, policy retryPolicy, discoveryURL, grantType string) (*oidcMetadata, error) {
+	var md *oidcMetadata
+	err := policy.do(ctx, func() error {
+		var err error
+		md, err = fetchOIDCMetadata(ctx, client, discoveryURL)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery at %s failed: %w", discoveryURL, err)
+	}
+	// The client secret or subject token is posted to token_endpoint, so it must stay on the configured host.
+	u, err := url.Parse(md.TokenEndpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("OIDC discovery at %s returned invalid token_endpoint %q", discoveryURL, md.TokenEndpoint)
+	}
+	if base, err := url.Parse(discoveryURL); err != nil || !strings.EqualFold(u.Host, base.Host) {
+		return nil, fmt.Errorf("OIDC discovery at %s returned token_endpoint %q on a different host", discoveryURL, md.TokenEndpoint)
+	}
+	if !slices.Contains(md.GrantTypesSupported, grantType) {
+		return nil, fmt.Errorf("token endpoint %s does not advertise grant type %s (supported: %s); "+
+			"check that the workspace has OAuth token federation or M2M enabled, or disable oidc_discovery",
+			md.TokenEndpoint, grantType, strings.Join(md.GrantTypesSupported, ", "))
+	}
+	return md, nil
+}
+
+// fetchOIDCMetadata makes a single discovery request. Transient failures are returned as *retryableError.
+func fetchOIDCMetadata(ctx context.Context, client *http.Client, discoveryURL string) (*oidcMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("discovery request failed: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("failed to read discovery response: %w", err)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("discovery returned %d: %s", resp.StatusCode, string(body))
+		if isRetryableStatus(resp.StatusCode) {
+			return nil, &retryableError{err: err, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		}
+		return nil, err
+	}
+
+	var md oidcMetadata
+	if err := json.Unmarshal(body, &md); err != nil {
+		return nil, fmt.Errorf("failed to parse discovery response: %w", err)
+	}
+	return &md, nil
+}
@@ -0,0 +1,132 @@
+package databricksauthextension
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// fakeDiscoveryServer serves authorization server metadata advertising grantTypes, with the token
+// endpoint at tokenPath on the same server. Requests to each are counted in discoveryHits and tokenHits.
+func fakeDiscoveryServer(t *testing.T, tokenPath string, grantTypes []string, discoveryHits, tokenHits *atomic.Int32) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/oidc" + oidcDiscoveryPath:
+			discoveryHits.Add(1)
+			json.NewEncoder(w).Encode(oidcMetadata{
+				Issuer:              server.URL + "/oidc",
+				TokenEndpoint:       server.URL + tokenPath,
+				GrantTypesSupported: grantTypes,
+			})
+		case tokenPath:
+			tokenHits.Add(1)
+			json.NewEncoder(w).Encode(tokenExchangeResponse{AccessToken: "discovered-token", TokenType: "Bearer", ExpiresIn: 3600})
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+// TestStart_OIDCDiscovery verifies Start keeps the discovered metadata and later refreshes use
+// its token endpoint without repeating discovery.
+func TestStart_OIDCDiscovery(t *testing.T) {
+	old := newAWSProvider
+	newAWSProvider = func(_ context.Context, _ *Config, _ *zap.Logger) (SubjectTokenSource, error) {
+		return &mockSubjectTokenSource{token: "aws-tok"}, nil
+	}
+	defer func() { newAWSProvider = old }()
+
+	var discoveryHits, tokenHits atomic.Int32
+	grants := []string{grantTypeClientCreds, grantTypeTokenExchange}
+	server := fakeDiscoveryServer(t, "/oidc/v2/token", grants, &discoveryHits, &tokenHits)
+	defer server.Close()
+
+	ext := newExt(&Config{SPClientID: "client-id", WorkspaceURL: server.URL, OIDCDiscovery: true})
+	if err := ext.Start(context.Background(), nil); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { _ = ext.Shutdown(context.Background()) })
+	md := ext.cache.discovered
+	if md == nil {
+		t.Fatal("discovered metadata not stored on the token cache")
+	}
+	if want := server.URL + "/oidc/v2/token"; md.TokenEndpoint != want || ext.cache.tokenEndpoint() != want {
+		t.Errorf("token endpoint = %q (cache %q), want %q", md.TokenEndpoint, ext.cache.tokenEndpoint(), want)
+	}
+	if len(md.GrantTypesSupported) != len(grants) {
+		t.Errorf("grant types = %v, want %v", md.GrantTypesSupported, grants)
+	}
+	token, err := ext.cache.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if token != "discovered-token" || tokenHits.Load() == 0 {
+		t.Errorf("token = %q (hits %d), want discovered-token from the discovered endpoint", token, tokenHits.Load())
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := ext.cache.Refresh(context.Background()); err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+	}
+	if got := discoveryHits.Load(); got != 1 {
+		t.Errorf("discovery requests = %d, want 1", got)
+	}
+}
+
+// TestStart_OIDCDiscovery_GrantNotAdvertised verifies Start fails fast when token exchange is unsupported.
+func TestStart_OIDCDiscovery_GrantNotAdvertised(t *testing.T) {
+	old := newAWSProvider
+	newAWSProvider = func(_ context.Context, _ *Config, _ *zap.Logger) (SubjectTokenSource, error) {
+		return &mockSubjectTokenSource{token: "aws-tok"}, nil
+	}
+	defer func() { newAWSProvider = old }()
+
+	var discoveryHits, tokenHits atomic.Int32
+	server := fakeDiscoveryServer(t, "/oidc/v1/token", []string{"authorization_code", grantTypeClientCreds}, &discoveryHits, &tokenHits)
+	defer server.Close()
+
+	ext := newExt(&Config{SPClientID: "client-id", WorkspaceURL: server.URL, OIDCDiscovery: true})
+	err := ext.Start(context.Background(), nil)
+	if err == nil {
+		_ = ext.Shutdown(context.Background())
+		t.Fatal("expected error when token exchange is not advertised, got nil")
+	}
+	if !strings.Contains(err.Error(), grantTypeTokenExchange) {
+		t.Errorf("err = %v, want it to name the missing grant type", err)
+	}
+}
+
+// TestDiscoverOIDC_Errors verifies invalid metadata and persistent failures are rejected.
+func TestDiscoverOIDC_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{name: "not found", handler: func(w http.ResponseWriter, _ *http.Request) { http.Error(w, "nope", http.StatusNotFound) }},
+		{name: "server error", handler: func(w http.ResponseWriter, _ *http.Request) { http.Error(w, "down", http.StatusServiceUnavailable) }},
+		{name: "malformed json", handler: func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("{")) }},
+		{name: "token endpoint on another host", handler: func(w http.ResponseWriter, _ *http.Request) {
+			json.NewEncoder(w).Encode(oidcMetadata{TokenEndpoint: "https://attacker.example.com/token", GrantTypesSupported: []string{grantTypeClientCreds}})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			_, err := discoverOIDC(context.Background(), server.Client(), retryPolicy{maxAttempts: 2}, server.URL+"/oidc"+oidcDiscoveryPath, grantTypeClientCreds)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package databricksauthextension
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultExecTimeout = 30 * time.Second // bounds a subject token command when exec.timeout is unset
+	maxExecStderrLog   = 4096             // bytes of stderr logged when a command fails
+)
+
+// execOutput is the JSON a subject token command prints, modelled on AWS credential_process and
+// Kubernetes exec credential plugins. Expiration (RFC 3339) is optional; without it the command
+// runs on every refresh.
+type execOutput struct {
+	Token      string    `json:"token"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// ExecTokenProvider supplies the subject token by running a command, for identity tokens only
+// available through a vendor CLI. Stdout is either the JSON execOutput or the bare token.
+type ExecTokenProvider struct {
+	command string
+	args    []string
+	env     []string
+	timeout time.Duration
+	logger  *zap.Logger
+
+	cache cachedSubjectToken // also serialises command runs
+}
+
+// NewExecTokenProvider returns a provider running cfg.Command. A nil logger disables logging.
+func NewExecTokenProvider(cfg ExecSourceConfig, logger *zap.Logger) *ExecTokenProvider {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	p := &ExecTokenProvider{
+		command: cfg.Command,
+		args:    cfg.Args,
+		timeout: cfg.Timeout,
+		logger:  logger,
+	}
+	if p.timeout == 0 {
+		p.timeout = defaultExecTimeout
+	}
+	if len(cfg.Env) > 0 {
+		p.env = os.Environ()
+		for k, v := range cfg.Env {
+			p.env = append(p.env, k+"="+v)
+		}
+	}
+	return p
+}
+
+// SubjectToken returns the cached token until near its reported expiry, otherwise runs the command.
+func (p *ExecTokenProvider) SubjectToken(ctx context.Context) (string, error) {
+	return p.cache.get(func() (string, time.Time, error) {
+		out, err := p.run(ctx)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		if !out.Expiration.IsZero() {
+			p.logger.Debug("Fetched subject token from command", zap.String("command", p.command), zap.Time("expiry", out.Expiration))
+		}
+		return out.Token, out.Expiration, nil
+	})
+}
+
+// run executes the command once and parses its output. On failure stderr is logged, since vendor
+// CLIs usually explain there why they could not issue a token.
+func (p *ExecTokenProvider) run(ctx context.Context) (execOutput, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.command, p.args...) // #nosec G204 -- command is operator configuration
+	cmd.Env = p.env                                       // nil inherits the collector's environment
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := stderr.String()
+		if len(msg) > maxExecStderrLog {
+			msg = msg[:maxExecStderrLog]
+		}
+		p.logger.Warn("Subject token command failed", zap.String("command", p.command), zap.Error(err), zap.String("stderr", strings.TrimSpace(msg)))
+		return execOutput{}, fmt.Errorf("subject token command %s failed: %w", p.command, err)
+	}
+
+	raw := strings.TrimSpace(stdout.String())
+	var out execOutput
+	if strings.HasPrefix(raw, "{") {
+		if err := json.Unmarshal([]byte(raw), &out); err != nil {
+			return execOutput{}, fmt.Errorf("failed to parse subject token command output: %w", err)
+		}
+		out.Token = strings.TrimSpace(out.Token)
+	} else {
+		out.Token = raw
+	}
+	if out.Token == "" {
+		return execOutput{}, fmt.Errorf("subject token command %s printed no token", p.command)
+	}
+	return out, nil
+}
@@ -0,0 +1,117 @@
+package databricksauthextension
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestExecTokenProvider_Stdout verifies bare stdout is the token.
+func TestExecTokenProvider_Stdout(t *testing.T) {
+	p := NewExecTokenProvider(ExecSourceConfig{Command: "sh", Args: []string{"-c", "printf ' exec-tok\\n'"}}, nil)
+	got, err := p.SubjectToken(context.Background())
+	if err != nil {
+		t.Fatalf("SubjectToken: %v", err)
+	}
+	if got != "exec-tok" {
+		t.Errorf("SubjectToken = %q, want exec-tok", got)
+	}
+}
+
+// TestExecTokenProvider_JSONCached verifies JSON output is parsed and cached until its expiration.
+func TestExecTokenProvider_JSONCached(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "runs")
+	expiration := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	script := fmt.Sprintf(`echo run >> %s; printf '{"token":"json-tok","expiration":"%s"}'`, counter, expiration)
+	p := NewExecTokenProvider(ExecSourceConfig{Command: "sh", Args: []string{"-c", script}}, nil)
+
+	for range 3 {
+		got, err := p.SubjectToken(context.Background())
+		if err != nil {
+			t.Fatalf("SubjectToken: %v", err)
+		}
+		if got != "json-tok" {
+			t.Errorf("SubjectToken = %q, want json-tok", got)
+		}
+	}
+	runs, _ := os.ReadFile(counter)
+	if n := strings.Count(string(runs), "run"); n != 1 {
+		t.Errorf("command ran %d times, want 1", n)
+	}
+}
+
+// TestExecTokenProvider_NoExpirationNotCached verifies tokens without an expiration are not reused.
+func TestExecTokenProvider_NoExpirationNotCached(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "runs")
+	script := fmt.Sprintf(`echo run >> %s; printf '{"token":"json-tok"}'`, counter)
+	p := NewExecTokenProvider(ExecSourceConfig{Command: "sh", Args: []string{"-c", script}}, nil)
+
+	for range 2 {
+		if _, err := p.SubjectToken(context.Background()); err != nil {
+			t.Fatalf("SubjectToken: %v", err)
+		}
+	}
+	runs, _ := os.ReadFile(counter)
+	if n := strings.Count(string(runs), "run"); n != 2 {
+		t.Errorf("command ran %d times, want 2", n)
+	}
+}
+
+// TestExecTokenProvider_Env verifies configured variables are passed to the command.
+func TestExecTokenProvider_Env(t *testing.T) {
+	p := NewExecTokenProvider(ExecSourceConfig{
+		Command: "sh",
+		Args:    []string{"-c", `printf '%s' "$VENDOR_AUDIENCE"`},
+		Env:     map[string]string{"VENDOR_AUDIENCE": "databricks"},
+	}, nil)
+	got, err := p.SubjectToken(context.Background())
+	if err != nil {
+		t.Fatalf("SubjectToken: %v", err)
+	}
+	if got != "databricks" {
+		t.Errorf("SubjectToken = %q, want databricks", got)
+	}
+}
+
+// TestExecTokenProvider_Failure verifies a failing command's stderr is logged.
+func TestExecTokenProvider_Failure(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	p := NewExecTokenProvider(ExecSourceConfig{Command: "sh", Args: []string{"-c", "echo 'not logged in' >&2; exit 1"}}, zap.New(core))
+	if _, err := p.SubjectToken(context.Background()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	entries := logs.FilterMessage("Subject token command failed").All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d failure logs, want 1", len(entries))
+	}
+	if stderr := entries[0].ContextMap()["stderr"]; stderr != "not logged in" {
+		t.Errorf("stderr = %q, want %q", stderr, "not logged in")
+	}
+}
+
+// TestExecTokenProvider_InvalidJSON verifies malformed JSON output is an error.
+func TestExecTokenProvider_InvalidJSON(t *testing.T) {
+	p := NewExecTokenProvider(ExecSourceConfig{Command: "sh", Args: []string{"-c", `printf '{"token":'`}}, nil)
+	if _, err := p.SubjectToken(context.Background()); err == nil {
+		t.Error("expected error for malformed JSON, got nil")
+	}
+}
+
+// TestExecTokenProvider_Timeout verifies a hung command is killed after the timeout.
+func TestExecTokenProvider_Timeout(t *testing.T) {
+	p := NewExecTokenProvider(ExecSourceConfig{Command: "sleep", Args: []string{"10"}, Timeout: 50 * time.Millisecond}, nil)
+	start := time.Now()
+	if _, err := p.SubjectToken(context.Background()); err == nil {
+		t.Fatal("expected timeout error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("SubjectToken took %v, want it bounded by the timeout", elapsed)
+	}
+}
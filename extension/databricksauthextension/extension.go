@@ -2,67 +2,390 @@ package databricksauthextension
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/credentials"
 )
 
+// ucTableHeader selects the Unity Catalog table the Databricks OTLP endpoint writes to.
+const ucTableHeader = "X-Databricks-UC-Table-Name"
+
 type databricksAuthExtension struct {
-	cfg    *Config
-	logger *zap.Logger
-	cache  *tokenCache // nil in static mode
+	cfg       *Config
+	logger    *zap.Logger
+	telemetry component.TelemetrySettings
+	cache     *tokenCache // nil in static mode
+
+	staticToken *staticTokenFile // set in static mode with token_file
+
+	// Background refresher lifecycle, owned by Start/Shutdown.
+	cancelRefresh context.CancelFunc
+	refreshDone   chan struct{}
+
+	// Component status reporting; only transitions are reported.
+	host       component.Host
+	statusMu   sync.Mutex
+	lastStatus componentstatus.Status
 }
 
 // newAWSProvider is the constructor used by Start. Replaced in tests to inject failures.
-var newAWSProvider = func(ctx context.Context) (AWSTokenProvider, error) {
-	return NewSTSTokenProvider(ctx)
+var newAWSProvider = func(ctx context.Context, cfg *Config, logger *zap.Logger) (SubjectTokenSource, error) {
+	policy := cfg.Retry.policy()
+	loadOpts := append(cfg.AWS.loadOptions(), awsconfig.WithRetryer(func() aws.Retryer { return stsRetryer(policy) }))
+	return NewSTSTokenProvider(ctx, logger, cfg.AWS.stsOptions(), loadOpts...)
+}
+
+// stsRetryer retries STS throttling with the SDK's standard retryer, using the same attempts and
+// backoff as the OIDC exchange.
+func stsRetryer(policy retryPolicy) aws.Retryer {
+	return retry.NewStandard(func(o *retry.StandardOptions) {
+		o.MaxAttempts = max(policy.maxAttempts, 1)
+		o.MaxBackoff = policy.maxInterval
+		o.Backoff = retry.BackoffDelayerFunc(func(attempt int, _ error) (time.Duration, error) {
+			return policy.backoff(attempt), nil
+		})
+	})
 }
 
-func (e *databricksAuthExtension) Start(ctx context.Context, _ component.Host) error {
+func (e *databricksAuthExtension) Start(ctx context.Context, host component.Host) error {
 	if e.cfg.SPClientID == "" {
-		return nil // static mode
+		return e.startStatic()
 	}
-	awsProvider, err := newAWSProvider(ctx)
+	e.host = host
+	logger := newRateLimitedLogger(e.logger, logSampleInterval)
+	var subjectSource SubjectTokenSource
+	if !e.cfg.isM2M() {
+		var err error
+		subjectSource, err = newSubjectTokenSource(ctx, e.cfg, logger)
+		if err != nil {
+			return err
+		}
+	}
+	httpClient, err := e.cfg.TokenEndpointClient.toClient()
 	if err != nil {
-		return fmt.Errorf("failed to init AWS provider: %w", err)
+		return fmt.Errorf("failed to build token endpoint client: %w", err)
+	}
+	var discovered *oidcMetadata
+	if e.cfg.OIDCDiscovery {
+		grantType := grantTypeTokenExchange
+		if e.cfg.isM2M() {
+			grantType = grantTypeClientCreds
+		}
+		discovered, err = discoverOIDC(ctx, httpClient, e.cfg.Retry.policy(), e.cfg.discoveryURL(), grantType)
+		if err != nil {
+			return err
+		}
+		logger.Debug("Discovered OIDC token endpoint", zap.String("token_endpoint", discovered.TokenEndpoint), zap.Strings("grant_types", discovered.GrantTypesSupported))
 	}
 	e.cache = &tokenCache{
-		workspaceURL: e.cfg.WorkspaceURL,
-		spClientID:   e.cfg.SPClientID,
-		expiryBuffer: e.cfg.expiryBufferOrDefault(),
-		awsProvider:  awsProvider,
-		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		workspaceURL:  e.cfg.WorkspaceURL,
+		tokenURL:      e.cfg.tokenEndpoint(),
+		discovered:    discovered,
+		spClientID:    e.cfg.SPClientID,
+		clientSecret:  e.cfg.ClientSecret,
+		expiryBuffer:  e.cfg.expiryBufferOrDefault(),
+		subjectSource: subjectSource,
+		sourceType:    e.cfg.subjectTokenSource().Type,
+		requestParams: e.cfg.requestParams(),
+		httpClient:    httpClient,
+		retry:         e.cfg.Retry.policy(),
+		graceMode:     e.cfg.GraceMode,
+		logger:        logger,
+	}
+	tel, err := newTokenTelemetry(e.telemetry.MeterProvider, e.cache.secondsUntilExpiry)
+	if err != nil {
+		return fmt.Errorf("failed to create telemetry: %w", err)
+	}
+	e.cache.telemetry = tel
+	e.cache.onRefresh = e.reportRefreshStatus
+	started := make(chan struct{})
+	defer close(started)
+	e.startRefresher(started)
+	return nil
+}
+
+// startStatic loads token_file, if configured, and starts watching it for rotation.
+// Static mode with an inline token needs no setup.
+func (e *databricksAuthExtension) startStatic() error {
+	if e.cfg.TokenFile == "" {
+		return nil
+	}
+	f, err := newStaticTokenFile(e.cfg.TokenFile, newRateLimitedLogger(e.logger, logSampleInterval))
+	if err != nil {
+		return err
 	}
+	e.staticToken = f
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancelRefresh = cancel
+	e.refreshDone = make(chan struct{})
+	go func() {
+		defer close(e.refreshDone)
+		f.watch(ctx)
+	}()
 	return nil
 }
 
-func (e *databricksAuthExtension) Shutdown(_ context.Context) error { return nil }
+// reportRefreshStatus maps a refresh outcome to component status so the health check reflects
+// auth state: OK on success, PermanentError for collector misconfiguration, RecoverableError otherwise.
+// PermanentError is terminal in the collector's status state machine, so nothing is reported after it.
+//
+// A federation policy mismatch or unauthorized_client was originally classified as permanent. It is
+// deliberately reported as recoverable, because it clears once fixed in Databricks and a terminal
+// status would hide that; the event's error names it so it is not mistaken for a transient failure.
+func (e *databricksAuthExtension) reportRefreshStatus(err error) {
+	if errors.Is(err, context.Canceled) {
+		return // shutting down or caller gave up; says nothing about auth health
+	}
+	var ev *componentstatus.Event
+	switch {
+	case err == nil:
+		ev = componentstatus.NewEvent(componentstatus.StatusOK)
+	case isPermanentAuthError(err):
+		ev = componentstatus.NewPermanentErrorEvent(err)
+	case databricksSideAuthError(err) != nil:
+		ev = componentstatus.NewRecoverableErrorEvent(fmt.Errorf(
+			"databricksauth: service principal or federation policy misconfigured in Databricks; retrying until it is fixed: %w",
+			databricksSideAuthError(err)))
+	default:
+		ev = componentstatus.NewRecoverableErrorEvent(err)
+	}
+
+	e.statusMu.Lock()
+	defer e.statusMu.Unlock()
+	if ev.Status() == e.lastStatus || e.lastStatus == componentstatus.StatusPermanentError {
+		return
+	}
+	e.lastStatus = ev.Status()
+	componentstatus.ReportStatus(e.host, ev)
+}
+
+// Shutdown stops the background refresher or token_file watcher, waiting for it to exit or ctx to expire,
+// and unregisters internal metrics.
+func (e *databricksAuthExtension) Shutdown(ctx context.Context) error {
+	var errs []error
+	if e.cancelRefresh != nil {
+		e.cancelRefresh()
+		select {
+		case <-e.refreshDone:
+		case <-ctx.Done():
+			errs = append(errs, ctx.Err())
+		}
+	}
+	if e.cache != nil {
+		errs = append(errs, e.cache.telemetry.shutdown())
+	}
+	return errors.Join(errs...)
+}
+
+// startRefresher launches a goroutine that renews the cached token ahead of expiry so the
+// request path rarely pays for an exchange. GetToken's lazy refresh remains as a fallback.
+//
+// The first refresh waits for started to close, once Start has returned: the collector reports
+// StatusOK for a successful Start, which would otherwise overwrite an earlier refresh failure.
+func (e *databricksAuthExtension) startRefresher(started <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancelRefresh = cancel
+	e.refreshDone = make(chan struct{})
+	go func() {
+		defer close(e.refreshDone)
+		select {
+		case <-ctx.Done():
+			return
+		case <-started:
+		}
+		wait := e.cache.nextRefreshIn()
+		for {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if _, err := e.cache.Refresh(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				// The failure itself is logged by the token cache.
+				wait = max(refreshRetryInterval, retryAfter(err))
+				e.logger.Debug("Background token refresh failed; will retry", zap.Duration("retry_in", wait))
+				continue
+			}
+			wait = e.cache.nextRefreshIn()
+		}
+	}()
+}
+
+// getToken returns the bearer token for the current mode: cached federation token, token_file
+// contents or static token.
+func (e *databricksAuthExtension) getToken(ctx context.Context) (string, error) {
+	if e.cache != nil {
+		return e.cache.GetToken(ctx)
+	}
+	if e.staticToken != nil {
+		return e.staticToken.Token(), nil
+	}
+	return string(e.cfg.Token), nil
+}
+
+// checkDestination refuses to attach credentials to plain http (unless allow_insecure_http is set)
+// or to hosts outside the allowlist, so a misconfigured endpoint or redirect cannot leak the token.
+func (e *databricksAuthExtension) checkDestination(u *url.URL) error {
+	if u.Scheme != "https" && !e.cfg.AllowInsecureHTTP {
+		return fmt.Errorf("databricksauth: refusing to send credentials over %q to %s; use https or set allow_insecure_http", u.Scheme, u.Host)
+	}
+	return e.checkHost(u.Hostname())
+}
+
+func (e *databricksAuthExtension) checkHost(host string) error {
+	allowed := e.cfg.allowedHosts()
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, a := range allowed {
+		if hostMatches(a, host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("databricksauth: refusing to send credentials to host %q: not in allowed_hosts %v", host, allowed)
+}
+
+// hostMatches compares hostnames case-insensitively; a "*." pattern matches any subdomain.
+func hostMatches(pattern, host string) bool {
+	pattern, host = strings.ToLower(pattern), strings.ToLower(host)
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return pattern == host
+}
 
 // RoundTripper implements extensionauth.HTTPClient.
 func (e *databricksAuthExtension) RoundTripper(base http.RoundTripper) (http.RoundTripper, error) {
 	return &bearerRoundTripper{ext: e, base: base}, nil
 }
 
+// PerRPCCredentials implements extensionauth.GRPCClient.
+func (e *databricksAuthExtension) PerRPCCredentials() (credentials.PerRPCCredentials, error) {
+	return &bearerPerRPCCredentials{ext: e}, nil
+}
+
 type bearerRoundTripper struct {
 	ext  *databricksAuthExtension
 	base http.RoundTripper
 }
 
 func (rt *bearerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	var token string
-	if rt.ext.cache != nil {
-		var err error
-		token, err = rt.ext.cache.GetToken(req.Context())
+	if err := rt.ext.checkDestination(req.URL); err != nil {
+		return nil, err
+	}
+	token, err := rt.ext.getToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rt.base.RoundTrip(rt.authorize(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || rt.ext.cache == nil {
+		return resp, err
+	}
+	return rt.replayUnauthorized(req, token, resp)
+}
+
+// authorize returns a clone of req carrying the bearer token and, if configured, the UC table header.
+func (rt *bearerRoundTripper) authorize(req *http.Request, token string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	// An explicit exporter header takes precedence over uc_tables.
+	if r.Header.Get(ucTableHeader) == "" {
+		if table := rt.ext.cfg.UCTables.tableForPath(r.URL.Path); table != "" {
+			r.Header.Set(ucTableHeader, table)
+		}
+	}
+	return r
+}
+
+// replayUnauthorized handles a 401 from Databricks, which means the token was revoked or the SP's
+// permissions changed: it invalidates the cached token, forces a fresh exchange and replays the
+// request once. The original response is returned if the body cannot be replayed, the refresh
+// fails, or a 401 already forced a refresh within forcedRefreshWindow.
+func (rt *bearerRoundTripper) replayUnauthorized(req *http.Request, token string, resp *http.Response) (*http.Response, error) {
+	ctx := req.Context()
+	tel := rt.ext.cache.telemetry
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		tel.recordUnauthorizedReplay(ctx, replayOutcomeNotReplayable)
+		return resp, nil
+	}
+
+	if !rt.ext.cache.invalidate(token) {
+		tel.recordUnauthorizedReplay(ctx, replayOutcomeRateLimited)
+		return resp, nil
+	}
+	newToken, err := rt.ext.getToken(ctx)
+	if err != nil {
+		tel.recordUnauthorizedReplay(ctx, replayOutcomeRefreshFailed)
+		return resp, nil
+	}
+
+	replay := rt.authorize(req, newToken)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			tel.recordUnauthorizedReplay(ctx, replayOutcomeNotReplayable)
+			return resp, nil
+		}
+		replay.Body = body
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	resp, err = rt.base.RoundTrip(replay)
+	switch {
+	case err != nil:
+		tel.recordUnauthorizedReplay(ctx, replayOutcomeError)
+	case resp.StatusCode == http.StatusUnauthorized:
+		tel.recordUnauthorizedReplay(ctx, replayOutcomeStillUnauthorized)
+	default:
+		tel.recordUnauthorizedReplay(ctx, replayOutcomeRecovered)
+	}
+	return resp, err
+}
+
+// bearerPerRPCCredentials attaches the Databricks token to every gRPC call.
+type bearerPerRPCCredentials struct {
+	ext *databricksAuthExtension
+}
+
+func (c *bearerPerRPCCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	if len(uri) > 0 {
+		u, err := url.Parse(uri[0])
 		if err != nil {
+			return nil, fmt.Errorf("databricksauth: invalid request URI %q: %w", uri[0], err)
+		}
+		// Transport security is enforced by RequireTransportSecurity; only the host is checked here.
+		if err := c.ext.checkHost(u.Hostname()); err != nil {
 			return nil, err
 		}
-	} else {
-		token = string(rt.ext.cfg.Token)
 	}
-	r := req.Clone(req.Context())
-	r.Header.Set("Authorization", "Bearer "+token)
-	return rt.base.RoundTrip(r)
+	token, err := c.ext.getToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity reports that bearer tokens must only be sent over TLS, unless
+// allow_insecure_http permits plaintext as it does for HTTP.
+func (c *bearerPerRPCCredentials) RequireTransportSecurity() bool {
+	return !c.ext.cfg.AllowInsecureHTTP
 }
@@ -2,16 +2,24 @@ package databricksauthextension
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
 	"go.opentelemetry.io/collector/config/configopaque"
 	"go.opentelemetry.io/collector/extension"
+	"go.opentelemetry.io/otel/metric/embedded"
 	"go.uber.org/zap"
 )
 
@@ -34,7 +42,7 @@ func TestRoundTripper_StaticMode(t *testing.T) {
 	backend := fakeBackend(t, &gotAuth)
 	defer backend.Close()
 
-	ext := newExt(&Config{Token: configopaque.String("my-static-token")})
+	ext := newExt(&Config{Token: configopaque.String("my-static-token"), AllowInsecureHTTP: true})
 	rt, err := ext.RoundTripper(http.DefaultTransport)
 	if err != nil {
 		t.Fatalf("RoundTripper: %v", err)
@@ -60,19 +68,22 @@ func TestRoundTripper_FederationMode(t *testing.T) {
 	defer backend.Close()
 
 	ext := newExt(&Config{
-		SPClientID:   "client-id",
-		WorkspaceURL: "https://adb-123.cloud.databricks.com",
+		SPClientID:        "client-id",
+		WorkspaceURL:      "https://adb-123.cloud.databricks.com",
+		AllowedHosts:      []string{"127.0.0.1"}, // httptest backend
+		AllowInsecureHTTP: true,
 	})
 
 	// Inject a pre-populated cache so no real AWS/OIDC calls are made.
 	ext.cache = &tokenCache{
-		workspaceURL: "https://adb-123.cloud.databricks.com",
-		spClientID:   "client-id",
-		expiryBuffer: 5 * time.Minute,
-		awsProvider:  &mockAWSTokenProvider{token: "aws-tok"},
-		httpClient:   &http.Client{},
-		cachedToken:  "federated-token",
-		tokenExpiry:  time.Now().Add(1 * time.Hour),
+		workspaceURL:  "https://adb-123.cloud.databricks.com",
+		spClientID:    "client-id",
+		expiryBuffer:  5 * time.Minute,
+		subjectSource: &mockSubjectTokenSource{token: "aws-tok"},
+		httpClient:    &http.Client{},
+		logger:        zap.NewNop(),
+		cachedToken:   "federated-token",
+		tokenExpiry:   time.Now().Add(1 * time.Hour),
 	}
 
 	rt, err := ext.RoundTripper(http.DefaultTransport)
@@ -107,11 +118,12 @@ func TestRoundTripper_PropagatesGetTokenError(t *testing.T) {
 	defer server.Close()
 
 	ext.cache = &tokenCache{
-		workspaceURL: server.URL,
-		spClientID:   "client-id",
-		expiryBuffer: 5 * time.Minute,
-		awsProvider:  &mockAWSTokenProvider{err: fmt.Errorf("aws down")},
-		httpClient:   &http.Client{Timeout: 5 * time.Second},
+		workspaceURL:  server.URL,
+		spClientID:    "client-id",
+		expiryBuffer:  5 * time.Minute,
+		subjectSource: &mockSubjectTokenSource{err: fmt.Errorf("aws down")},
+		httpClient:    &http.Client{Timeout: 5 * time.Second},
+		logger:        zap.NewNop(),
 	}
 
 	rt, err := ext.RoundTripper(http.DefaultTransport)
@@ -137,7 +149,32 @@ func TestStart_StaticMode(t *testing.T) {
 	}
 }
 
-// TestShutdown verifies Shutdown is always a no-op.
+// TestStart_TokenFile verifies static mode with token_file serves the file's token and that
+// Shutdown stops the watcher.
+func TestStart_TokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("dapi-from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ext := newExt(&Config{TokenFile: path})
+	if err := ext.Start(context.Background(), nil); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	token, err := ext.getToken(context.Background())
+	if err != nil || token != "dapi-from-file" {
+		t.Errorf("getToken() = %q, %v; want dapi-from-file", token, err)
+	}
+	if err := ext.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	missing := newExt(&Config{TokenFile: filepath.Join(t.TempDir(), "missing")})
+	if err := missing.Start(context.Background(), nil); err == nil {
+		t.Error("expected Start error for missing token_file")
+	}
+}
+
+// TestShutdown verifies Shutdown is a no-op when no background refresher was started.
 func TestShutdown(t *testing.T) {
 	ext := newExt(&Config{Token: configopaque.String("tok")})
 	if err := ext.Shutdown(context.Background()); err != nil {
@@ -145,6 +182,33 @@ func TestShutdown(t *testing.T) {
 	}
 }
 
+// failingRegistration is a metric registration whose Unregister fails.
+type failingRegistration struct{ embedded.Registration }
+
+func (failingRegistration) Unregister() error { return errors.New("unregister failed") }
+
+// TestShutdown_StopsRefresherWhenTelemetryFails verifies the background refresher is stopped even
+// if unregistering internal metrics fails, and that the telemetry error is still returned.
+func TestShutdown_StopsRefresherWhenTelemetryFails(t *testing.T) {
+	ext := newExt(&Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com"})
+	ext.cache = newTestTokenCache("https://adb-123.cloud.databricks.com", &mockSubjectTokenSource{token: "aws-tok"})
+	ext.cache.cachedToken = "tok"
+	ext.cache.tokenExpiry = time.Now().Add(1 * time.Hour)
+	ext.cache.telemetry = &tokenTelemetry{registration: failingRegistration{}}
+	started := make(chan struct{})
+	close(started)
+	ext.startRefresher(started)
+
+	if err := ext.Shutdown(context.Background()); err == nil || !strings.Contains(err.Error(), "unregister failed") {
+		t.Errorf("Shutdown error = %v, want unregister failure", err)
+	}
+	select {
+	case <-ext.refreshDone:
+	default:
+		t.Error("background refresher still running after Shutdown")
+	}
+}
+
 // TestStart_FederationMode verifies Start initialises the tokenCache when sp_client_id is set.
 func TestStart_FederationMode(t *testing.T) {
 	ext := newExt(&Config{
@@ -154,6 +218,7 @@ func TestStart_FederationMode(t *testing.T) {
 	if err := ext.Start(context.Background(), nil); err != nil {
 		t.Fatalf("Start: %v", err)
 	}
+	t.Cleanup(func() { _ = ext.Shutdown(context.Background()) })
 	if ext.cache == nil {
 		t.Error("expected tokenCache to be initialised in federation mode")
 	}
@@ -162,7 +227,7 @@ func TestStart_FederationMode(t *testing.T) {
 // TestStart_FederationMode_AWSError verifies Start returns an error when the AWS provider fails.
 func TestStart_FederationMode_AWSError(t *testing.T) {
 	old := newAWSProvider
-	newAWSProvider = func(_ context.Context) (AWSTokenProvider, error) {
+	newAWSProvider = func(_ context.Context, _ *Config, _ *zap.Logger) (SubjectTokenSource, error) {
 		return nil, fmt.Errorf("no credentials")
 	}
 	defer func() { newAWSProvider = old }()
@@ -176,6 +241,32 @@ func TestStart_FederationMode_AWSError(t *testing.T) {
 	}
 }
 
+// TestStart_ServiceAccountTokenFile verifies Start uses the token file instead of AWS STS.
+func TestStart_ServiceAccountTokenFile(t *testing.T) {
+	old := newAWSProvider
+	newAWSProvider = func(_ context.Context, _ *Config, _ *zap.Logger) (SubjectTokenSource, error) {
+		return nil, fmt.Errorf("AWS provider must not be initialised with service_account_token_file")
+	}
+	defer func() { newAWSProvider = old }()
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("k8s-sa-jwt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ext := newExt(&Config{
+		SPClientID:              "client-id",
+		WorkspaceURL:            "https://adb-123.cloud.databricks.com",
+		ServiceAccountTokenFile: path,
+	})
+	if err := ext.Start(context.Background(), nil); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { _ = ext.Shutdown(context.Background()) })
+	if _, ok := ext.cache.subjectSource.(*FileTokenProvider); !ok {
+		t.Errorf("subject token provider = %T, want *FileTokenProvider", ext.cache.subjectSource)
+	}
+}
+
 // TestCreateDefaultConfig verifies the factory creates a zero-value *Config.
 func TestCreateDefaultConfig(t *testing.T) {
 	cfg := createDefaultConfig()
@@ -219,7 +310,7 @@ func TestRoundTripper_DoesNotMutateOriginalRequest(t *testing.T) {
 	}))
 	defer backend.Close()
 
-	ext := newExt(&Config{Token: configopaque.String("tok")})
+	ext := newExt(&Config{Token: configopaque.String("tok"), AllowInsecureHTTP: true})
 	rt, _ := ext.RoundTripper(http.DefaultTransport)
 
 	orig, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, backend.URL, nil)
@@ -236,3 +327,587 @@ func TestRoundTripper_DoesNotMutateOriginalRequest(t *testing.T) {
 		t.Error("original request was mutated with Authorization header")
 	}
 }
+
+// TestPerRPCCredentials_StaticMode verifies the static token is returned as gRPC authorization metadata.
+func TestPerRPCCredentials_StaticMode(t *testing.T) {
+	ext := newExt(&Config{Token: configopaque.String("my-static-token")})
+	creds, err := ext.PerRPCCredentials()
+	if err != nil {
+		t.Fatalf("PerRPCCredentials: %v", err)
+	}
+
+	md, err := creds.GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatalf("GetRequestMetadata: %v", err)
+	}
+	want := "Bearer my-static-token"
+	if md["authorization"] != want {
+		t.Errorf("authorization = %q, want %q", md["authorization"], want)
+	}
+}
+
+// TestPerRPCCredentials_FederationMode verifies the gRPC path uses the same token cache as HTTP.
+func TestPerRPCCredentials_FederationMode(t *testing.T) {
+	ext := newExt(&Config{
+		SPClientID:   "client-id",
+		WorkspaceURL: "https://adb-123.cloud.databricks.com",
+	})
+	ext.cache = &tokenCache{
+		workspaceURL:  "https://adb-123.cloud.databricks.com",
+		spClientID:    "client-id",
+		expiryBuffer:  5 * time.Minute,
+		subjectSource: &mockSubjectTokenSource{token: "aws-tok"},
+		httpClient:    &http.Client{},
+		logger:        zap.NewNop(),
+		cachedToken:   "federated-token",
+		tokenExpiry:   time.Now().Add(1 * time.Hour),
+	}
+
+	creds, err := ext.PerRPCCredentials()
+	if err != nil {
+		t.Fatalf("PerRPCCredentials: %v", err)
+	}
+
+	md, err := creds.GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatalf("GetRequestMetadata: %v", err)
+	}
+	want := "Bearer federated-token"
+	if md["authorization"] != want {
+		t.Errorf("authorization = %q, want %q", md["authorization"], want)
+	}
+}
+
+// TestPerRPCCredentials_PropagatesGetTokenError verifies a GetToken failure surfaces from GetRequestMetadata.
+func TestPerRPCCredentials_PropagatesGetTokenError(t *testing.T) {
+	ext := newExt(&Config{
+		SPClientID:   "client-id",
+		WorkspaceURL: "https://adb-123.cloud.databricks.com",
+	})
+	ext.cache = &tokenCache{
+		workspaceURL:  "https://adb-123.cloud.databricks.com",
+		spClientID:    "client-id",
+		expiryBuffer:  5 * time.Minute,
+		subjectSource: &mockSubjectTokenSource{err: fmt.Errorf("aws down")},
+		httpClient:    &http.Client{Timeout: 5 * time.Second},
+		logger:        zap.NewNop(),
+	}
+
+	creds, err := ext.PerRPCCredentials()
+	if err != nil {
+		t.Fatalf("PerRPCCredentials: %v", err)
+	}
+	if _, err := creds.GetRequestMetadata(context.Background()); err == nil {
+		t.Fatal("expected error from GetToken, got nil")
+	}
+}
+
+// TestPerRPCCredentials_RequireTransportSecurity verifies bearer tokens are only sent over
+// plaintext gRPC when allow_insecure_http is set, matching the HTTP round tripper.
+func TestPerRPCCredentials_RequireTransportSecurity(t *testing.T) {
+	for _, allowInsecure := range []bool{false, true} {
+		ext := newExt(&Config{Token: configopaque.String("tok"), AllowInsecureHTTP: allowInsecure})
+		creds, err := ext.PerRPCCredentials()
+		if err != nil {
+			t.Fatalf("PerRPCCredentials: %v", err)
+		}
+		if got := creds.RequireTransportSecurity(); got != !allowInsecure {
+			t.Errorf("allow_insecure_http=%v: RequireTransportSecurity = %v, want %v", allowInsecure, got, !allowInsecure)
+		}
+	}
+}
+
+// TestStart_M2MMode verifies Start skips AWS provider init when client_secret is set.
+func TestStart_M2MMode(t *testing.T) {
+	old := newAWSProvider
+	newAWSProvider = func(_ context.Context, _ *Config, _ *zap.Logger) (SubjectTokenSource, error) {
+		return nil, fmt.Errorf("AWS provider must not be initialised in M2M mode")
+	}
+	defer func() { newAWSProvider = old }()
+
+	ext := newExt(&Config{
+		SPClientID:   "client-id",
+		ClientSecret: "secret",
+		WorkspaceURL: "https://adb-123.cloud.databricks.com",
+	})
+	if err := ext.Start(context.Background(), nil); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { _ = ext.Shutdown(context.Background()) })
+	if ext.cache == nil {
+		t.Fatal("expected tokenCache to be initialised in M2M mode")
+	}
+	if ext.cache.clientSecret != "secret" {
+		t.Errorf("clientSecret = %q, want %q", ext.cache.clientSecret, "secret")
+	}
+}
+
+// TestBackgroundRefresher_RenewsAndStopsOnShutdown verifies Start launches a proactive refresher
+// that keeps renewing the token, and that Shutdown stops it.
+func TestBackgroundRefresher_RenewsAndStopsOnShutdown(t *testing.T) {
+	oldMin, oldProvider := minRefreshInterval, newAWSProvider
+	minRefreshInterval = 10 * time.Millisecond
+	newAWSProvider = func(_ context.Context, _ *Config, _ *zap.Logger) (SubjectTokenSource, error) {
+		return &mockSubjectTokenSource{token: "aws-tok"}, nil
+	}
+	defer func() { minRefreshInterval, newAWSProvider = oldMin, oldProvider }()
+
+	var counter atomic.Int32
+	server := createMockOIDCServerWithCounter(t, "bg-token", 1, &counter)
+	defer server.Close()
+
+	ext := newExt(&Config{SPClientID: "client-id", WorkspaceURL: server.URL})
+	if err := ext.Start(context.Background(), nil); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for counter.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := counter.Load(); got < 3 {
+		t.Fatalf("expected at least 3 background refreshes, got %d", got)
+	}
+
+	if err := ext.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	stopped := counter.Load()
+	time.Sleep(50 * time.Millisecond)
+	if got := counter.Load(); got != stopped {
+		t.Errorf("refresher kept running after Shutdown: %d -> %d exchanges", stopped, got)
+	}
+}
+
+// TestBackgroundRefresher_RetriesAfterFailure verifies a failed proactive refresh is retried.
+func TestBackgroundRefresher_RetriesAfterFailure(t *testing.T) {
+	oldRetry, oldProvider := refreshRetryInterval, newAWSProvider
+	refreshRetryInterval = 10 * time.Millisecond
+	mock := &mockSubjectTokenSource{err: fmt.Errorf("aws down")}
+	newAWSProvider = func(_ context.Context, _ *Config, _ *zap.Logger) (SubjectTokenSource, error) { return mock, nil }
+	defer func() { refreshRetryInterval, newAWSProvider = oldRetry, oldProvider }()
+
+	ext := newExt(&Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com"})
+	if err := ext.Start(context.Background(), nil); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() { _ = ext.Shutdown(context.Background()) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for mock.callCount.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := mock.callCount.Load(); got < 2 {
+		t.Fatalf("expected background refresher to retry after failure, got %d attempts", got)
+	}
+}
+
+// TestBackgroundRefresher_HonoursRetryAfter verifies the refresher waits for a Retry-After longer
+// than its own retry interval before trying again.
+func TestBackgroundRefresher_HonoursRetryAfter(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	oldRetry, oldProvider := refreshRetryInterval, newAWSProvider
+	refreshRetryInterval = 10 * time.Millisecond
+	newAWSProvider = func(_ context.Context, _ *Config, _ *zap.Logger) (SubjectTokenSource, error) {
+		return &mockSubjectTokenSource{token: "aws-tok"}, nil
+	}
+	defer func() { refreshRetryInterval, newAWSProvider = oldRetry, oldProvider }()
+
+	ext := newExt(&Config{SPClientID: "client-id", WorkspaceURL: server.URL, Retry: RetryConfig{MaxAttempts: 1}})
+	if err := ext.Start(context.Background(), nil); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() { _ = ext.Shutdown(context.Background()) }()
+
+	time.Sleep(300 * time.Millisecond)
+	if got := hits.Load(); got != 1 {
+		t.Errorf("token endpoint hit %d times within Retry-After, want 1", got)
+	}
+}
+
+// statusHost is a component.Host that records reported component status events.
+type statusHost struct {
+	mu     sync.Mutex
+	events []*componentstatus.Event
+}
+
+func (h *statusHost) GetExtensions() map[component.ID]component.Component { return nil }
+
+func (h *statusHost) Report(ev *componentstatus.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, ev)
+}
+
+func (h *statusHost) statuses() []componentstatus.Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]componentstatus.Status, len(h.events))
+	for i, ev := range h.events {
+		out[i] = ev.Status()
+	}
+	return out
+}
+
+// TestReportRefreshStatus verifies refresh outcomes are reported as component status transitions.
+func TestReportRefreshStatus(t *testing.T) {
+	host := &statusHost{}
+	ext := newExt(&Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com"})
+	ext.host = host
+
+	ext.reportRefreshStatus(nil)
+	ext.reportRefreshStatus(nil) // already OK: no event
+	ext.reportRefreshStatus(&retryableError{err: &tokenEndpointError{StatusCode: http.StatusServiceUnavailable}})
+	ext.reportRefreshStatus(fmt.Errorf("failed to get AWS token: %w", fmt.Errorf("throttled"))) // still recoverable: no event
+	ext.reportRefreshStatus(nil)
+	ext.reportRefreshStatus(context.Canceled) // ignored
+	ext.reportRefreshStatus(&tokenEndpointError{StatusCode: http.StatusUnauthorized, Code: "invalid_client"})
+	ext.reportRefreshStatus(nil) // PermanentError is terminal: no event
+
+	want := []componentstatus.Status{
+		componentstatus.StatusOK,
+		componentstatus.StatusRecoverableError,
+		componentstatus.StatusOK,
+		componentstatus.StatusPermanentError,
+	}
+	got := host.statuses()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("reported statuses = %v, want %v", got, want)
+	}
+}
+
+// TestStart_ReportsStatusFromRefresher verifies a failing background refresh is surfaced via the host,
+// as recoverable since a federation policy fixed in Databricks clears it.
+func TestStart_ReportsStatusFromRefresher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_request","error_description":"Token does not match any federation policy"}`)
+	}))
+	defer server.Close()
+
+	old := newAWSProvider
+	newAWSProvider = func(_ context.Context, _ *Config, _ *zap.Logger) (SubjectTokenSource, error) {
+		return &mockSubjectTokenSource{token: "aws-tok"}, nil
+	}
+	defer func() { newAWSProvider = old }()
+
+	host := &statusHost{}
+	ext := newExt(&Config{SPClientID: "client-id", WorkspaceURL: server.URL})
+	if err := ext.Start(context.Background(), host); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() { _ = ext.Shutdown(context.Background()) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(host.statuses()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	got := host.statuses()
+	if len(got) != 1 || got[0] != componentstatus.StatusRecoverableError {
+		t.Fatalf("reported statuses = %v, want [StatusRecoverableError]", got)
+	}
+	host.mu.Lock()
+	msg := host.events[0].Err().Error()
+	host.mu.Unlock()
+	for _, want := range []string{"federation policy misconfigured", "invalid_request", "Token does not match any federation policy"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("status error %q does not mention %q", msg, want)
+		}
+	}
+}
+
+// TestStartRefresher_WaitsForStart verifies the first background refresh, and so its status
+// report, happens only once Start has returned.
+func TestStartRefresher_WaitsForStart(t *testing.T) {
+	mock := &mockSubjectTokenSource{err: fmt.Errorf("aws down")}
+	host := &statusHost{}
+	ext := newExt(&Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com"})
+	ext.host = host
+	ext.cache = newTestTokenCache("https://adb-123.cloud.databricks.com", mock)
+	ext.cache.onRefresh = ext.reportRefreshStatus
+	started := make(chan struct{})
+	ext.startRefresher(started)
+	defer func() { _ = ext.Shutdown(context.Background()) }()
+
+	time.Sleep(50 * time.Millisecond)
+	if got := mock.callCount.Load(); got != 0 || len(host.statuses()) != 0 {
+		t.Fatalf("refresher ran before Start returned: %d exchanges, statuses %v", got, host.statuses())
+	}
+	close(started)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(host.statuses()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := host.statuses(); len(got) != 1 || got[0] != componentstatus.StatusRecoverableError {
+		t.Errorf("reported statuses = %v, want [StatusRecoverableError]", got)
+	}
+}
+
+// TestRoundTripper_InjectsUCTableHeader verifies uc_tables selects the table from the signal path,
+// and that an explicit exporter header is left alone.
+func TestRoundTripper_InjectsUCTableHeader(t *testing.T) {
+	var gotTable string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTable = r.Header.Get(ucTableHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	ext := newExt(&Config{
+		Token:             configopaque.String("tok"),
+		AllowInsecureHTTP: true,
+		UCTables:          UCTablesConfig{Catalog: "main", Schema: "otel", TablePrefix: "prod"},
+	})
+	rt, _ := ext.RoundTripper(http.DefaultTransport)
+
+	tests := []struct {
+		path     string
+		explicit string
+		want     string
+	}{
+		{path: "/api/2.0/otel/v1/traces", want: "main.otel.prod_otel_spans"},
+		{path: "/api/2.0/otel/v1/metrics", want: "main.otel.prod_otel_metrics"},
+		{path: "/api/2.0/otel/v1/logs", want: "main.otel.prod_otel_logs"},
+		{path: "/api/2.0/otel/v1/logs", explicit: "main.otel.override", want: "main.otel.override"},
+		{path: "/healthz", want: ""},
+	}
+	for _, tt := range tests {
+		gotTable = ""
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, backend.URL+tt.path, nil)
+		if tt.explicit != "" {
+			req.Header.Set(ucTableHeader, tt.explicit)
+		}
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip(%s): %v", tt.path, err)
+		}
+		resp.Body.Close()
+		if gotTable != tt.want {
+			t.Errorf("%s: %s = %q, want %q", tt.path, ucTableHeader, gotTable, tt.want)
+		}
+	}
+}
+
+// TestRoundTripper_RefusesDisallowedDestinations verifies the token is never sent to other hosts or over plain http.
+func TestRoundTripper_RefusesDisallowedDestinations(t *testing.T) {
+	var hits atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name string
+		cfg  *Config
+	}{
+		{
+			name: "host outside workspace_url",
+			cfg:  &Config{Token: "tok", WorkspaceURL: "https://adb-123.cloud.databricks.com", AllowInsecureHTTP: true},
+		},
+		{
+			name: "host outside allowed_hosts",
+			cfg:  &Config{Token: "tok", AllowedHosts: []string{"*.cloud.databricks.com"}, AllowInsecureHTTP: true},
+		},
+		{
+			name: "plain http without allow_insecure_http",
+			cfg:  &Config{Token: "tok"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, _ := newExt(tt.cfg).RoundTripper(http.DefaultTransport)
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, backend.URL, nil)
+			if _, err := rt.RoundTrip(req); err == nil {
+				t.Fatal("expected RoundTrip to refuse the destination, got nil error")
+			}
+		})
+	}
+	if hits.Load() != 0 {
+		t.Errorf("backend received %d requests, want 0", hits.Load())
+	}
+}
+
+// TestPerRPCCredentials_RefusesDisallowedHost verifies gRPC metadata is withheld for hosts outside the allowlist.
+func TestPerRPCCredentials_RefusesDisallowedHost(t *testing.T) {
+	ext := newExt(&Config{Token: "tok", WorkspaceURL: "https://adb-123.cloud.databricks.com"})
+	creds, _ := ext.PerRPCCredentials()
+
+	if _, err := creds.GetRequestMetadata(context.Background(), "https://evil.example.com:443/opentelemetry.proto"); err == nil {
+		t.Error("expected error for host outside allowlist, got nil")
+	}
+	md, err := creds.GetRequestMetadata(context.Background(), "https://adb-123.cloud.databricks.com:443/opentelemetry.proto")
+	if err != nil {
+		t.Fatalf("GetRequestMetadata: %v", err)
+	}
+	if md["authorization"] != "Bearer tok" {
+		t.Errorf("authorization = %q, want %q", md["authorization"], "Bearer tok")
+	}
+}
+
+func TestHostMatches(t *testing.T) {
+	tests := []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"adb-123.cloud.databricks.com", "ADB-123.cloud.databricks.com", true},
+		{"adb-123.cloud.databricks.com", "adb-456.cloud.databricks.com", false},
+		{"*.cloud.databricks.com", "adb-123.cloud.databricks.com", true},
+		{"*.cloud.databricks.com", "cloud.databricks.com", false},
+		{"*.cloud.databricks.com", "evilcloud.databricks.com", false},
+	}
+	for _, tt := range tests {
+		if got := hostMatches(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("hostMatches(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+// unauthorizedBackend rejects every token except validToken with 401 and records request bodies.
+func unauthorizedBackend(t *testing.T, validToken string, hits *atomic.Int32, bodies *[]string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		*bodies = append(*bodies, string(b))
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer "+validToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+// TestRoundTripper_ReplaysOnceAfter401 verifies a 401 invalidates the cached token, forces a fresh
+// exchange and replays the request body once with the new token.
+func TestRoundTripper_ReplaysOnceAfter401(t *testing.T) {
+	var hits, exchanges atomic.Int32
+	var bodies []string
+	backend := unauthorizedBackend(t, "fresh-token", &hits, &bodies)
+	defer backend.Close()
+	oidc := createMockOIDCServerWithCounter(t, "fresh-token", 3600, &exchanges)
+	defer oidc.Close()
+
+	ext := newExt(&Config{SPClientID: "client-id", WorkspaceURL: oidc.URL, AllowInsecureHTTP: true, AllowedHosts: []string{"127.0.0.1"}})
+	ext.cache = newTestTokenCache(oidc.URL, &mockSubjectTokenSource{token: "aws-tok"})
+	ext.cache.cachedToken = "revoked-token"
+	ext.cache.tokenExpiry = time.Now().Add(1 * time.Hour)
+	reader := newTestTelemetry(t, ext.cache)
+
+	rt, _ := ext.RoundTripper(http.DefaultTransport)
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, backend.URL+"/api/2.0/otel/v1/traces", strings.NewReader("payload"))
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200 after replay", resp.StatusCode)
+	}
+	if hits.Load() != 2 || exchanges.Load() != 1 {
+		t.Errorf("backend hits = %d, exchanges = %d, want 2 and 1", hits.Load(), exchanges.Load())
+	}
+	if fmt.Sprint(bodies) != "[payload payload]" {
+		t.Errorf("request bodies = %v, want the payload sent twice", bodies)
+	}
+	replays := collectMetrics(t, reader)["otelcol_databricksauth_unauthorized_replays"]
+	if got := sumValue(t, replays, outcomeKey.String(replayOutcomeRecovered)); got != 1 {
+		t.Errorf("recovered replays = %d, want 1", got)
+	}
+}
+
+// TestRoundTripper_401ReplayedAtMostOnce verifies a persistent 401 is returned after a single replay,
+// and that further 401s within forcedRefreshWindow do not force more token exchanges.
+func TestRoundTripper_401ReplayedAtMostOnce(t *testing.T) {
+	var hits, exchanges atomic.Int32
+	var bodies []string
+	backend := unauthorizedBackend(t, "never-valid", &hits, &bodies)
+	defer backend.Close()
+	oidc := createMockOIDCServerWithCounter(t, "fresh-token", 3600, &exchanges)
+	defer oidc.Close()
+
+	ext := newExt(&Config{SPClientID: "client-id", WorkspaceURL: oidc.URL, AllowInsecureHTTP: true, AllowedHosts: []string{"127.0.0.1"}})
+	ext.cache = newTestTokenCache(oidc.URL, &mockSubjectTokenSource{token: "aws-tok"})
+	reader := newTestTelemetry(t, ext.cache)
+
+	rt, _ := ext.RoundTripper(http.DefaultTransport)
+	for i := range 3 {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, backend.URL, strings.NewReader("payload"))
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip %d: %v", i, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("request %d: status = %d, want 401", i, resp.StatusCode)
+		}
+	}
+
+	// Initial exchange plus one forced by the first 401; later requests are not replayed.
+	if exchanges.Load() != 2 {
+		t.Errorf("token exchanges = %d, want 2", exchanges.Load())
+	}
+	if hits.Load() != 4 {
+		t.Errorf("backend hits = %d, want 4", hits.Load())
+	}
+	replays := collectMetrics(t, reader)["otelcol_databricksauth_unauthorized_replays"]
+	if got := sumValue(t, replays, outcomeKey.String(replayOutcomeStillUnauthorized)); got != 1 {
+		t.Errorf("still_unauthorized replays = %d, want 1", got)
+	}
+	if got := sumValue(t, replays, outcomeKey.String(replayOutcomeRateLimited)); got != 2 {
+		t.Errorf("rate_limited replays = %d, want 2", got)
+	}
+}
+
+// TestRoundTripper_401NotReplayedWithoutGetBody verifies bodies that cannot be rewound are not replayed.
+func TestRoundTripper_401NotReplayedWithoutGetBody(t *testing.T) {
+	var hits, exchanges atomic.Int32
+	var bodies []string
+	backend := unauthorizedBackend(t, "fresh-token", &hits, &bodies)
+	defer backend.Close()
+	oidc := createMockOIDCServerWithCounter(t, "fresh-token", 3600, &exchanges)
+	defer oidc.Close()
+
+	ext := newExt(&Config{SPClientID: "client-id", WorkspaceURL: oidc.URL, AllowInsecureHTTP: true, AllowedHosts: []string{"127.0.0.1"}})
+	ext.cache = newTestTokenCache(oidc.URL, &mockSubjectTokenSource{token: "aws-tok"})
+	ext.cache.cachedToken = "revoked-token"
+	ext.cache.tokenExpiry = time.Now().Add(1 * time.Hour)
+
+	rt, _ := ext.RoundTripper(http.DefaultTransport)
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, backend.URL, io.NopCloser(strings.NewReader("payload")))
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized || hits.Load() != 1 {
+		t.Errorf("status = %d, hits = %d, want 401 and 1", resp.StatusCode, hits.Load())
+	}
+}
+
+// TestStart_InvalidTokenEndpointClient verifies Start fails fast when the token endpoint TLS settings cannot be loaded.
+func TestStart_InvalidTokenEndpointClient(t *testing.T) {
+	ext := newExt(&Config{
+		SPClientID:          "client-id",
+		ClientSecret:        "secret",
+		WorkspaceURL:        "https://adb-123.cloud.databricks.com",
+		TokenEndpointClient: TokenEndpointClientConfig{TLS: TLSClientConfig{CAFile: "/nonexistent/ca.pem"}},
+	})
+	if err := ext.Start(context.Background(), nil); err == nil {
+		_ = ext.Shutdown(context.Background())
+		t.Fatal("expected error for unreadable ca_file, got nil")
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/extension"
+	"go.uber.org/zap"
 )
 
 var componentType = component.MustNewType("databricksauth")
@@ -23,9 +24,16 @@ func createDefaultConfig() component.Config {
 }
 
 func createExtension(_ context.Context, set extension.Settings, cfg component.Config) (extension.Extension, error) {
-	c := cfg.(*Config)
+	c, sources, err := cfg.(*Config).resolve()
+	if err != nil {
+		return nil, err
+	}
+	if from := sources.String(); from != "" {
+		set.Logger.Info("Using Databricks unified auth settings", zap.String("sources", from))
+	}
 	return &databricksAuthExtension{
-		cfg:    c,
-		logger: set.Logger,
+		cfg:       c,
+		logger:    set.Logger,
+		telemetry: set.TelemetrySettings,
 	}, nil
 }
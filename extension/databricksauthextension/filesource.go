@@ -0,0 +1,40 @@
+package databricksauthextension
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileTokenProvider supplies the subject token from a file, typically a Kubernetes projected
+// service account token whose issuer is trusted by a Databricks federation policy.
+//
+// The kubelet rotates projected tokens by atomically swapping the file, so the file is re-read
+// on every call; calls only happen on token refresh, so this is cheap.
+type FileTokenProvider struct {
+	path string
+}
+
+// NewFileTokenProvider returns a provider reading the token at path. The file is checked once
+// so a misconfigured path fails at Start rather than on the first export.
+func NewFileTokenProvider(path string) (*FileTokenProvider, error) {
+	p := &FileTokenProvider{path: path}
+	if _, err := p.SubjectToken(context.Background()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SubjectToken returns the current contents of the token file, trimmed of whitespace.
+func (p *FileTokenProvider) SubjectToken(_ context.Context) (string, error) {
+	b, err := os.ReadFile(p.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", p.path)
+	}
+	return token, nil
+}
@@ -0,0 +1,80 @@
+package databricksauthextension
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFileTokenProvider_Rotation verifies the file is re-read so a rotated token is picked up.
+func TestFileTokenProvider_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("sa-token-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewFileTokenProvider(path)
+	if err != nil {
+		t.Fatalf("NewFileTokenProvider: %v", err)
+	}
+	if token, _ := p.SubjectToken(context.Background()); token != "sa-token-1" {
+		t.Errorf("token = %q, want sa-token-1", token)
+	}
+
+	// Simulate the kubelet's atomic swap.
+	tmp := path + ".new"
+	if err := os.WriteFile(tmp, []byte("sa-token-2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	if token, _ := p.SubjectToken(context.Background()); token != "sa-token-2" {
+		t.Errorf("token after rotation = %q, want sa-token-2", token)
+	}
+}
+
+// TestNewFileTokenProvider_Errors verifies a missing or empty file fails construction.
+func TestNewFileTokenProvider_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewFileTokenProvider(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for missing file, got nil")
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte(" \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileTokenProvider(empty); err == nil {
+		t.Error("expected error for empty file, got nil")
+	}
+}
+
+// TestTokenCache_FileSubjectToken verifies the file contents are exchanged as the subject token.
+func TestTokenCache_FileSubjectToken(t *testing.T) {
+	var gotSubject string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		gotSubject = r.PostForm.Get("subject_token")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"dbx-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("k8s-sa-jwt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewFileTokenProvider(path)
+	if err != nil {
+		t.Fatalf("NewFileTokenProvider: %v", err)
+	}
+	token, err := newTestTokenCache(server.URL, p).GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if token != "dbx-token" || gotSubject != "k8s-sa-jwt" {
+		t.Errorf("token = %q, subject_token = %q; want dbx-token, k8s-sa-jwt", token, gotSubject)
+	}
+}
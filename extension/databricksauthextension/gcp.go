@@ -0,0 +1,98 @@
+package databricksauthextension
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultGCPMetadataHost   = "metadata.google.internal"
+	defaultGCPServiceAccount = "default"
+	gcpIdentityPath          = "/computeMetadata/v1/instance/service-accounts/%s/identity"
+)
+
+// GCPTokenProvider supplies the subject token as a Google-signed ID token for the instance's
+// service account, fetched from the GCE metadata server (GCE, GKE workload identity, Cloud Run).
+type GCPTokenProvider struct {
+	endpoint   string // identity URL without query
+	audience   string
+	httpClient *http.Client
+	logger     *zap.Logger
+
+	cache cachedSubjectToken
+}
+
+// NewGCPTokenProvider returns a provider for cfg. A nil logger disables logging.
+func NewGCPTokenProvider(cfg GCPSourceConfig, logger *zap.Logger) *GCPTokenProvider {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	host := cfg.MetadataHost
+	if host == "" {
+		host = defaultGCPMetadataHost
+	}
+	account := cfg.ServiceAccount
+	if account == "" {
+		account = defaultGCPServiceAccount
+	}
+	return &GCPTokenProvider{
+		endpoint:   "http://" + host + fmt.Sprintf(gcpIdentityPath, url.PathEscape(account)),
+		audience:   cfg.Audience,
+		httpClient: newMetadataHTTPClient(),
+		logger:     logger,
+	}
+}
+
+// SubjectToken returns an ID token for the configured audience, caching it until near expiry.
+func (p *GCPTokenProvider) SubjectToken(ctx context.Context) (string, error) {
+	return p.cache.get(func() (string, time.Time, error) {
+		token, err := p.fetch(ctx)
+		if err != nil {
+			p.logger.Warn("GCP metadata identity token request failed", zap.String("endpoint", p.endpoint), zap.Error(err))
+			return "", time.Time{}, err
+		}
+		expiry, ok := jwtExpiry(token)
+		if !ok {
+			expiry = time.Now().Add(5 * time.Minute)
+		}
+		p.logger.Debug("Fetched GCP identity token", zap.Time("expiry", expiry))
+		return token, expiry, nil
+	})
+}
+
+func (p *GCPTokenProvider) fetch(ctx context.Context) (string, error) {
+	q := url.Values{}
+	q.Set("audience", p.audience)
+	q.Set("format", "full")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create metadata request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("metadata request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read metadata response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned %d: %s", resp.StatusCode, string(body))
+	}
+	token := strings.TrimSpace(string(body))
+	if token == "" {
+		return "", fmt.Errorf("metadata server returned empty token")
+	}
+	return token, nil
+}
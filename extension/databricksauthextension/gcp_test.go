@@ -0,0 +1,77 @@
+package databricksauthextension
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testJWT returns an unsigned JWT whose exp claim is expiry.
+func testJWT(expiry time.Time) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		enc.EncodeToString([]byte(fmt.Sprintf(`{"sub":"svc","exp":%d}`, expiry.Unix()))) + ".sig"
+}
+
+// TestGCPTokenProvider verifies the metadata request and that the ID token is cached until its exp.
+func TestGCPTokenProvider(t *testing.T) {
+	idToken := testJWT(time.Now().Add(time.Hour))
+	var calls atomic.Int32
+	var gotPath, gotAudience string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+			return
+		}
+		gotPath, gotAudience = r.URL.Path, r.URL.Query().Get("audience")
+		_, _ = w.Write([]byte(idToken))
+	}))
+	defer server.Close()
+
+	p := NewGCPTokenProvider(GCPSourceConfig{
+		Audience:     "https://accounts.cloud.databricks.com",
+		MetadataHost: strings.TrimPrefix(server.URL, "http://"),
+	}, nil)
+	for range 2 {
+		token, err := p.SubjectToken(context.Background())
+		if err != nil {
+			t.Fatalf("SubjectToken: %v", err)
+		}
+		if token != idToken {
+			t.Errorf("token = %q, want the metadata ID token", token)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("metadata calls = %d, want 1", n)
+	}
+	if gotPath != "/computeMetadata/v1/instance/service-accounts/default/identity" {
+		t.Errorf("path = %q, want default service account identity path", gotPath)
+	}
+	if gotAudience != "https://accounts.cloud.databricks.com" {
+		t.Errorf("audience = %q, want configured audience", gotAudience)
+	}
+}
+
+// TestGCPTokenProvider_Error verifies a metadata error status is surfaced.
+func TestGCPTokenProvider_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "service account not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	p := NewGCPTokenProvider(GCPSourceConfig{
+		Audience:       "aud",
+		MetadataHost:   strings.TrimPrefix(server.URL, "http://"),
+		ServiceAccount: "otel@project.iam.gserviceaccount.com",
+	}, nil)
+	if _, err := p.SubjectToken(context.Background()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
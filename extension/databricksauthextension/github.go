@@ -0,0 +1,112 @@
+package databricksauthextension
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Environment GitHub Actions sets for jobs granted the id-token: write permission.
+const (
+	githubTokenRequestURLEnv   = "ACTIONS_ID_TOKEN_REQUEST_URL"
+	githubTokenRequestTokenEnv = "ACTIONS_ID_TOKEN_REQUEST_TOKEN" // #nosec G101 -- environment variable name
+)
+
+// GitHubActionsTokenProvider supplies the subject token as a GitHub Actions OIDC token, for
+// collectors shipping telemetry from CI jobs.
+type GitHubActionsTokenProvider struct {
+	requestURL   string
+	requestToken string
+	audience     string
+	httpClient   *http.Client
+	logger       *zap.Logger
+
+	cache cachedSubjectToken
+}
+
+// NewGitHubActionsTokenProvider returns a provider for cfg. It fails when the job was not granted
+// the id-token: write permission, so the misconfiguration surfaces at Start. A nil logger disables
+// logging.
+func NewGitHubActionsTokenProvider(cfg GitHubSourceConfig, logger *zap.Logger) (*GitHubActionsTokenProvider, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	requestURL, requestToken := os.Getenv(githubTokenRequestURLEnv), os.Getenv(githubTokenRequestTokenEnv)
+	if requestURL == "" || requestToken == "" {
+		return nil, fmt.Errorf("%s and %s are not set; grant the job the id-token: write permission", githubTokenRequestURLEnv, githubTokenRequestTokenEnv)
+	}
+	return &GitHubActionsTokenProvider{
+		requestURL:   requestURL,
+		requestToken: requestToken,
+		audience:     cfg.Audience,
+		httpClient:   &http.Client{Timeout: defaultMetadataTimeout},
+		logger:       logger,
+	}, nil
+}
+
+// SubjectToken returns a GitHub Actions OIDC token, caching it until near expiry.
+func (p *GitHubActionsTokenProvider) SubjectToken(ctx context.Context) (string, error) {
+	return p.cache.get(func() (string, time.Time, error) {
+		token, err := p.fetch(ctx)
+		if err != nil {
+			p.logger.Warn("GitHub Actions OIDC token request failed", zap.Error(err))
+			return "", time.Time{}, err
+		}
+		expiry, ok := jwtExpiry(token)
+		if !ok {
+			expiry = time.Now().Add(5 * time.Minute)
+		}
+		p.logger.Debug("Fetched GitHub Actions OIDC token", zap.Time("expiry", expiry))
+		return token, expiry, nil
+	})
+}
+
+func (p *GitHubActionsTokenProvider) fetch(ctx context.Context) (string, error) {
+	u, err := url.Parse(p.requestURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", githubTokenRequestURLEnv, err)
+	}
+	if p.audience != "" {
+		q := u.Query()
+		q.Set("audience", p.audience)
+		u.RawQuery = q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create OIDC token request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.requestToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("OIDC token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read OIDC token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GitHub OIDC token endpoint returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var out struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", fmt.Errorf("failed to parse OIDC token response: %w", err)
+	}
+	if out.Value == "" {
+		return "", fmt.Errorf("GitHub OIDC token endpoint returned empty token")
+	}
+	return out.Value, nil
+}
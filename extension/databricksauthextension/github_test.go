@@ -0,0 +1,78 @@
+package databricksauthextension
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestGitHubActionsTokenProvider verifies the OIDC request and that the token is cached until its exp.
+func TestGitHubActionsTokenProvider(t *testing.T) {
+	idToken := testJWT(time.Now().Add(time.Hour))
+	var calls atomic.Int32
+	var gotAuth, gotAudience, gotAPIVersion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		gotAuth = r.Header.Get("Authorization")
+		gotAudience, gotAPIVersion = r.URL.Query().Get("audience"), r.URL.Query().Get("api-version")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"count":1,"value":%q}`, idToken)
+	}))
+	defer server.Close()
+
+	t.Setenv(githubTokenRequestURLEnv, server.URL+"/token?api-version=2.0")
+	t.Setenv(githubTokenRequestTokenEnv, "runtime-token")
+	p, err := NewGitHubActionsTokenProvider(GitHubSourceConfig{Audience: "databricks"}, nil)
+	if err != nil {
+		t.Fatalf("NewGitHubActionsTokenProvider: %v", err)
+	}
+	for range 2 {
+		token, err := p.SubjectToken(context.Background())
+		if err != nil {
+			t.Fatalf("SubjectToken: %v", err)
+		}
+		if token != idToken {
+			t.Errorf("token = %q, want the OIDC token", token)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("token requests = %d, want 1", n)
+	}
+	if gotAuth != "Bearer runtime-token" {
+		t.Errorf("Authorization = %q, want Bearer runtime-token", gotAuth)
+	}
+	if gotAudience != "databricks" || gotAPIVersion != "2.0" {
+		t.Errorf("audience = %q, api-version = %q; want databricks, 2.0", gotAudience, gotAPIVersion)
+	}
+}
+
+// TestGitHubActionsTokenProvider_MissingEnv verifies a job without id-token: write fails fast.
+func TestGitHubActionsTokenProvider_MissingEnv(t *testing.T) {
+	t.Setenv(githubTokenRequestURLEnv, "")
+	t.Setenv(githubTokenRequestTokenEnv, "")
+	if _, err := NewGitHubActionsTokenProvider(GitHubSourceConfig{}, nil); err == nil {
+		t.Fatal("expected error when the request environment is missing, got nil")
+	}
+}
+
+// TestGitHubActionsTokenProvider_Error verifies an error status is surfaced.
+func TestGitHubActionsTokenProvider_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	t.Setenv(githubTokenRequestURLEnv, server.URL)
+	t.Setenv(githubTokenRequestTokenEnv, "expired")
+	p, err := NewGitHubActionsTokenProvider(GitHubSourceConfig{}, nil)
+	if err != nil {
+		t.Fatalf("NewGitHubActionsTokenProvider: %v", err)
+	}
+	if _, err := p.SubjectToken(context.Background()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
@@ -0,0 +1,129 @@
+package databricksauthextension
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeServerCA writes the httptest TLS server's certificate to a PEM file and returns its path.
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, pemBytes, 0o600); err != nil {
+		t.Fatalf("write CA file: %v", err)
+	}
+	return path
+}
+
+func TestTokenEndpointClientConfig_toClientDefaults(t *testing.T) {
+	client, err := (&TokenEndpointClientConfig{}).toClient()
+	if err != nil {
+		t.Fatalf("toClient: %v", err)
+	}
+	if client.Timeout != defaultTokenEndpointTimeout {
+		t.Errorf("Timeout = %v, want %v", client.Timeout, defaultTokenEndpointTimeout)
+	}
+
+	client, err = (&TokenEndpointClientConfig{Timeout: 5 * time.Second}).toClient()
+	if err != nil {
+		t.Fatalf("toClient: %v", err)
+	}
+	if client.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want 5s", client.Timeout)
+	}
+}
+
+// TestTokenEndpointClientConfig_CAFile verifies a custom CA bundle is trusted for the token exchange.
+func TestTokenEndpointClientConfig_CAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokenExchangeResponse{AccessToken: "tls-token", ExpiresIn: 3600})
+	}))
+	defer server.Close()
+
+	// Without the CA the server certificate is untrusted.
+	untrusted, err := (&TokenEndpointClientConfig{}).toClient()
+	if err != nil {
+		t.Fatalf("toClient: %v", err)
+	}
+	cache := newTestTokenCache(server.URL, &mockSubjectTokenSource{token: "aws-token"})
+	cache.httpClient = untrusted
+	if _, err := cache.GetToken(context.Background()); err == nil {
+		t.Fatal("expected TLS verification error without ca_file, got nil")
+	}
+
+	trusted, err := (&TokenEndpointClientConfig{TLS: TLSClientConfig{CAFile: writeServerCA(t, server)}}).toClient()
+	if err != nil {
+		t.Fatalf("toClient: %v", err)
+	}
+	cache = newTestTokenCache(server.URL, &mockSubjectTokenSource{token: "aws-token"})
+	cache.httpClient = trusted
+	token, err := cache.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if token != "tls-token" {
+		t.Errorf("token = %q, want %q", token, "tls-token")
+	}
+}
+
+// TestTokenEndpointClientConfig_ProxyURL verifies the token exchange is sent through the configured proxy.
+func TestTokenEndpointClientConfig_ProxyURL(t *testing.T) {
+	var gotURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokenExchangeResponse{AccessToken: "proxied-token", ExpiresIn: 3600})
+	}))
+	defer proxy.Close()
+
+	client, err := (&TokenEndpointClientConfig{ProxyURL: proxy.URL}).toClient()
+	if err != nil {
+		t.Fatalf("toClient: %v", err)
+	}
+	cache := newTestTokenCache("http://adb-123.cloud.databricks.com", &mockSubjectTokenSource{token: "aws-token"})
+	cache.httpClient = client
+
+	token, err := cache.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if token != "proxied-token" {
+		t.Errorf("token = %q, want %q", token, "proxied-token")
+	}
+	if want := "http://adb-123.cloud.databricks.com" + oidcTokenEndpoint; gotURL != want {
+		t.Errorf("proxy saw %q, want %q", gotURL, want)
+	}
+}
+
+func TestTLSClientConfig_loadErrors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  TLSClientConfig
+	}{
+		{name: "missing ca_file", cfg: TLSClientConfig{CAFile: filepath.Join(dir, "missing.pem")}},
+		{name: "ca_file without certificates", cfg: TLSClientConfig{CAFile: notPEM}},
+		{name: "unreadable client key pair", cfg: TLSClientConfig{CertFile: notPEM, KeyFile: notPEM}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cfg.load(); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
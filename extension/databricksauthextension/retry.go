@@ -0,0 +1,100 @@
+package databricksauthextension
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryPolicy is the resolved form of RetryConfig. The zero value performs a single attempt.
+type retryPolicy struct {
+	maxAttempts     int
+	initialInterval time.Duration
+	maxInterval     time.Duration
+}
+
+// retryableError marks a token endpoint failure as transient (429, 5xx, connection error).
+// retryAfter, when non-zero, is the server-requested delay from a Retry-After header.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// isRetryableStatus reports whether an HTTP status from the token endpoint is worth retrying.
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// parseRetryAfter parses a Retry-After header given as delay-seconds or an HTTP-date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// retryAfter returns the Retry-After delay carried by err, or zero if there is none.
+func retryAfter(err error) time.Duration {
+	var rerr *retryableError
+	if errors.As(err, &rerr) {
+		return rerr.retryAfter
+	}
+	return 0
+}
+
+// backoff returns the delay before retry number attempt (1-based): exponential growth
+// from initialInterval capped at maxInterval, with equal jitter.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.initialInterval
+	for i := 1; i < attempt && d < p.maxInterval; i++ {
+		d *= 2
+	}
+	d = min(d, p.maxInterval)
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int64N(half)) // #nosec G404 -- jitter, not security-sensitive
+	}
+	return d
+}
+
+// do runs fn until it succeeds, returns a non-retryable error, or attempts are exhausted.
+// A Retry-After longer than maxInterval ends the retries: honouring it would stall the caller's
+// request path for as long as the server asks, and retrying sooner would ignore the server.
+func (p retryPolicy) do(ctx context.Context, fn func() error) error {
+	attempts := max(p.maxAttempts, 1)
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		var rerr *retryableError
+		if err == nil || !errors.As(err, &rerr) || attempt >= attempts {
+			break
+		}
+
+		wait := p.backoff(attempt)
+		if rerr.retryAfter > p.maxInterval {
+			break
+		}
+		if rerr.retryAfter > 0 {
+			wait = rerr.retryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+	return err
+}
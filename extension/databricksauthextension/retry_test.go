@@ -0,0 +1,158 @@
+package databricksauthextension
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "empty", value: "", want: 0},
+		{name: "delay seconds", value: "3", want: 3 * time.Second},
+		{name: "zero seconds", value: "0", want: 0},
+		{name: "garbage", value: "soon", want: 0},
+		{name: "past http date", value: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("future http date", func(t *testing.T) {
+		v := time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat)
+		got := parseRetryAfter(v)
+		if got <= 25*time.Second || got > 30*time.Second {
+			t.Errorf("parseRetryAfter(%q) = %v, want ~30s", v, got)
+		}
+	})
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := retryPolicy{maxAttempts: 5, initialInterval: 100 * time.Millisecond, maxInterval: 400 * time.Millisecond}
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{attempt: 1, ceiling: 100 * time.Millisecond},
+		{attempt: 2, ceiling: 200 * time.Millisecond},
+		{attempt: 3, ceiling: 400 * time.Millisecond},
+		{attempt: 10, ceiling: 400 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		got := p.backoff(tt.attempt)
+		if got < tt.ceiling/2 || got >= tt.ceiling {
+			t.Errorf("backoff(%d) = %v, want in [%v, %v)", tt.attempt, got, tt.ceiling/2, tt.ceiling)
+		}
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	p := retryPolicy{maxAttempts: 3, initialInterval: time.Millisecond, maxInterval: time.Millisecond}
+
+	t.Run("retries retryable errors until attempts are exhausted", func(t *testing.T) {
+		calls := 0
+		err := p.do(context.Background(), func() error {
+			calls++
+			return &retryableError{err: errors.New("503")}
+		})
+		if err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if calls != 3 {
+			t.Errorf("calls = %d, want 3", calls)
+		}
+	})
+
+	t.Run("stops on success", func(t *testing.T) {
+		calls := 0
+		err := p.do(context.Background(), func() error {
+			calls++
+			if calls < 2 {
+				return &retryableError{err: errors.New("503")}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("calls = %d, want 2", calls)
+		}
+	})
+
+	t.Run("does not retry non-retryable errors", func(t *testing.T) {
+		calls := 0
+		_ = p.do(context.Background(), func() error {
+			calls++
+			return errors.New("invalid_client")
+		})
+		if calls != 1 {
+			t.Errorf("calls = %d, want 1", calls)
+		}
+	})
+
+	t.Run("zero value performs a single attempt", func(t *testing.T) {
+		calls := 0
+		_ = retryPolicy{}.do(context.Background(), func() error {
+			calls++
+			return &retryableError{err: errors.New("503")}
+		})
+		if calls != 1 {
+			t.Errorf("calls = %d, want 1", calls)
+		}
+	})
+
+	t.Run("gives up on Retry-After beyond max_interval", func(t *testing.T) {
+		calls := 0
+		start := time.Now()
+		err := p.do(context.Background(), func() error {
+			calls++
+			return &retryableError{err: errors.New("429"), retryAfter: time.Hour}
+		})
+		if err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if calls != 1 {
+			t.Errorf("calls = %d, want 1", calls)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("do took %v, want it to return without waiting", elapsed)
+		}
+	})
+
+	t.Run("honours Retry-After within max_interval", func(t *testing.T) {
+		q := retryPolicy{maxAttempts: 2, initialInterval: time.Millisecond, maxInterval: time.Second}
+		calls := 0
+		start := time.Now()
+		_ = q.do(context.Background(), func() error {
+			calls++
+			return &retryableError{err: errors.New("429"), retryAfter: 50 * time.Millisecond}
+		})
+		if calls != 2 {
+			t.Errorf("calls = %d, want 2", calls)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("do took %v, want at least the 50ms Retry-After", elapsed)
+		}
+	})
+
+	t.Run("stops waiting when context is cancelled", func(t *testing.T) {
+		slow := retryPolicy{maxAttempts: 3, initialInterval: time.Hour, maxInterval: time.Hour}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := slow.do(ctx, func() error { return &retryableError{err: errors.New("503")} })
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want context.Canceled", err)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package databricksauthextension
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// tokenFilePollInterval is how often token_file is checked for changes. Replaced in tests.
+var tokenFilePollInterval = 10 * time.Second
+
+// staticTokenFile holds the static token read from token_file and reloads it when the file
+// changes, so a rotated PAT is picked up without restarting the collector.
+//
+// Changes are detected by polling os.Stat rather than with inotify: Kubernetes secret and
+// Vault agent mounts replace the file through symlink swaps that watches on the path miss.
+type staticTokenFile struct {
+	path   string
+	file   FileTokenProvider // reads and trims the file, as for service_account_token_file
+	logger *zap.Logger
+
+	token atomic.Pointer[string]
+	info  os.FileInfo // last loaded; only touched by load and the watch goroutine
+}
+
+// newStaticTokenFile reads the token at path. The file must be readable and non-empty so a
+// misconfigured path fails at Start rather than on the first export.
+func newStaticTokenFile(path string, logger *zap.Logger) (*staticTokenFile, error) {
+	f := &staticTokenFile{path: path, file: FileTokenProvider{path: path}, logger: logger}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Token returns the most recently loaded token.
+func (f *staticTokenFile) Token() string {
+	return *f.token.Load()
+}
+
+// load reads the file and swaps in its trimmed contents. On error the previous token is kept.
+func (f *staticTokenFile) load() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat token_file: %w", err)
+	}
+	token, err := f.file.SubjectToken(context.Background())
+	if err != nil {
+		return err
+	}
+	f.token.Store(&token)
+	f.info = info
+	return nil
+}
+
+// changed reports whether the file differs from the last successful load.
+func (f *staticTokenFile) changed() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, err
+	}
+	return !os.SameFile(info, f.info) || !info.ModTime().Equal(f.info.ModTime()) || info.Size() != f.info.Size(), nil
+}
+
+// watch polls the file until ctx is cancelled, reloading it on change. Failures are logged and
+// retried on the next poll; requests keep using the previous token meanwhile.
+func (f *staticTokenFile) watch(ctx context.Context) {
+	ticker := time.NewTicker(tokenFilePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := f.changed()
+		if err != nil {
+			f.logger.Warn("Failed to check token_file; keeping previous token", zap.String("path", f.path), zap.Error(err))
+			continue
+		}
+		if !changed {
+			continue
+		}
+		if err := f.load(); err != nil {
+			f.logger.Warn("Failed to reload token_file; keeping previous token", zap.String("path", f.path), zap.Error(err))
+			continue
+		}
+		f.logger.Info("Reloaded static token from token_file", zap.String("path", f.path))
+	}
+}
@@ -0,0 +1,92 @@
+package databricksauthextension
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func writeTokenFile(t *testing.T, path, content string) {
+	t.Helper()
+	// Write and rename like a secret mount update so the watcher never sees a partial file.
+	tmp := path + ".new"
+	if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitForToken polls f until it returns want or the deadline passes.
+func waitForToken(t *testing.T, f *staticTokenFile, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for f.Token() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("token = %q, want %q", f.Token(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestStaticTokenFile_Reload verifies a rotated token is swapped in, and that unreadable or empty
+// updates keep the previous token until a valid one is written.
+func TestStaticTokenFile_Reload(t *testing.T) {
+	old := tokenFilePollInterval
+	tokenFilePollInterval = 10 * time.Millisecond
+	defer func() { tokenFilePollInterval = old }()
+
+	path := filepath.Join(t.TempDir(), "token")
+	writeTokenFile(t, path, "  dapi-1\n")
+	f, err := newStaticTokenFile(path, zap.NewNop())
+	if err != nil {
+		t.Fatalf("newStaticTokenFile: %v", err)
+	}
+	if got := f.Token(); got != "dapi-1" {
+		t.Fatalf("token = %q, want dapi-1", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.watch(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	writeTokenFile(t, path, "dapi-2\n")
+	waitForToken(t, f, "dapi-2")
+
+	writeTokenFile(t, path, " \n")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * tokenFilePollInterval)
+	if got := f.Token(); got != "dapi-2" {
+		t.Errorf("token after empty/removed file = %q, want dapi-2 retained", got)
+	}
+
+	writeTokenFile(t, path, "dapi-3")
+	waitForToken(t, f, "dapi-3")
+}
+
+// TestNewStaticTokenFile_Errors verifies a missing or empty file fails construction.
+func TestNewStaticTokenFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := newStaticTokenFile(filepath.Join(dir, "missing"), zap.NewNop()); err == nil {
+		t.Error("expected error for missing file")
+	}
+	empty := filepath.Join(dir, "empty")
+	writeTokenFile(t, empty, "\n")
+	if _, err := newStaticTokenFile(empty, zap.NewNop()); err == nil {
+		t.Error("expected error for empty file")
+	}
+}
@@ -0,0 +1,221 @@
+package databricksauthextension
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Subject token source types, selected by subject_token_source.type.
+const (
+	sourceAWSSTS = "aws_sts" // default
+	sourceFile   = "file"
+	sourceEnv    = "env"
+	sourceExec   = "exec"
+	sourceAzure  = "azure_managed_identity"
+	sourceGCP    = "gcp_metadata"
+	sourceGitHub = "github_actions"
+)
+
+// defaultMetadataTimeout bounds requests to cloud metadata and CI token services.
+const defaultMetadataTimeout = 10 * time.Second
+
+// subjectTokenExpiryBuffer is how long before its expiry a cached subject token is replaced.
+const subjectTokenExpiryBuffer = 30 * time.Second
+
+// SubjectTokenSource supplies the identity token exchanged for a Databricks token in federation
+// mode (RFC 8693 subject_token) — mockable in tests.
+type SubjectTokenSource interface {
+	SubjectToken(ctx context.Context) (string, error)
+}
+
+// cachedSubjectToken caches a provider's subject token until subjectTokenExpiryBuffer before it
+// expires. Providers supply only the fetch; a token fetched without an expiry is never reused.
+type cachedSubjectToken struct {
+	mu     sync.Mutex // held across fetch, so concurrent callers share one fetch
+	token  string
+	expiry time.Time
+}
+
+// get returns the cached token, or calls fetch and caches its result.
+func (c *cachedSubjectToken) get(fetch func() (string, time.Time, error)) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expiry.Add(-subjectTokenExpiryBuffer)) {
+		return c.token, nil
+	}
+	token, expiry, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	c.token, c.expiry = token, expiry
+	return token, nil
+}
+
+// subjectTokenSourceType registers a subject token source: how its settings are validated and
+// how it is built at Start. Adding an identity provider only needs a new entry in subjectTokenSources.
+type subjectTokenSourceType struct {
+	validate func(src *SubjectTokenSourceConfig) error // nil when the type has no required settings
+	create   func(ctx context.Context, cfg *Config, logger *zap.Logger) (SubjectTokenSource, error)
+}
+
+// subjectTokenSources holds the registered source types, keyed by subject_token_source.type.
+var subjectTokenSources = map[string]subjectTokenSourceType{
+	sourceAWSSTS: {
+		create: func(ctx context.Context, cfg *Config, logger *zap.Logger) (SubjectTokenSource, error) {
+			return newAWSProvider(ctx, cfg, logger)
+		},
+	},
+	sourceFile: {
+		validate: func(src *SubjectTokenSourceConfig) error {
+			if src.File.Path == "" {
+				return errors.New("subject_token_source.file.path is required for type file")
+			}
+			return nil
+		},
+		create: func(_ context.Context, cfg *Config, _ *zap.Logger) (SubjectTokenSource, error) {
+			return NewFileTokenProvider(cfg.subjectTokenSource().File.Path)
+		},
+	},
+	sourceEnv: {
+		validate: func(src *SubjectTokenSourceConfig) error {
+			if src.Env.Name == "" {
+				return errors.New("subject_token_source.env.name is required for type env")
+			}
+			return nil
+		},
+		create: func(_ context.Context, cfg *Config, _ *zap.Logger) (SubjectTokenSource, error) {
+			return NewEnvTokenProvider(cfg.subjectTokenSource().Env.Name)
+		},
+	},
+	sourceExec: {
+		validate: func(src *SubjectTokenSourceConfig) error {
+			if src.Exec.Command == "" {
+				return errors.New("subject_token_source.exec.command is required for type exec")
+			}
+			if src.Exec.Timeout < 0 {
+				return errors.New("subject_token_source.exec.timeout must not be negative")
+			}
+			return nil
+		},
+		create: func(_ context.Context, cfg *Config, logger *zap.Logger) (SubjectTokenSource, error) {
+			return NewExecTokenProvider(cfg.subjectTokenSource().Exec, logger), nil
+		},
+	},
+	sourceAzure: {
+		validate: func(src *SubjectTokenSourceConfig) error {
+			if src.Azure.Endpoint != "" {
+				if u, err := url.Parse(src.Azure.Endpoint); err != nil || u.Host == "" {
+					return fmt.Errorf("subject_token_source.azure.endpoint %q must be an absolute URL", src.Azure.Endpoint)
+				}
+			}
+			return nil
+		},
+		create: func(_ context.Context, cfg *Config, logger *zap.Logger) (SubjectTokenSource, error) {
+			return NewAzureTokenProvider(cfg.subjectTokenSource().Azure, logger), nil
+		},
+	},
+	sourceGCP: {
+		validate: func(src *SubjectTokenSourceConfig) error {
+			if src.GCP.Audience == "" {
+				return errors.New("subject_token_source.gcp.audience is required for type gcp_metadata")
+			}
+			if strings.ContainsAny(src.GCP.MetadataHost, "/?#") {
+				return fmt.Errorf("subject_token_source.gcp.metadata_host %q must be a host[:port]", src.GCP.MetadataHost)
+			}
+			return nil
+		},
+		create: func(_ context.Context, cfg *Config, logger *zap.Logger) (SubjectTokenSource, error) {
+			return NewGCPTokenProvider(cfg.subjectTokenSource().GCP, logger), nil
+		},
+	},
+	sourceGitHub: {
+		create: func(_ context.Context, cfg *Config, logger *zap.Logger) (SubjectTokenSource, error) {
+			return NewGitHubActionsTokenProvider(cfg.subjectTokenSource().GitHub, logger)
+		},
+	},
+}
+
+// newSubjectTokenSource builds the configured federation subject token source (AWS STS by default).
+func newSubjectTokenSource(ctx context.Context, cfg *Config, logger *zap.Logger) (SubjectTokenSource, error) {
+	typ := cfg.subjectTokenSource().Type
+	reg, ok := subjectTokenSources[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown subject_token_source type %q", typ)
+	}
+	src, err := reg.create(ctx, cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init %s subject token source: %w", typ, err)
+	}
+	return src, nil
+}
+
+// registeredSubjectTokenSources returns the registered type names, sorted for error messages.
+func registeredSubjectTokenSources() []string {
+	return slices.Sorted(maps.Keys(subjectTokenSources))
+}
+
+// newMetadataHTTPClient returns a client for link-local metadata services. Proxies are bypassed:
+// metadata endpoints are only reachable from the instance itself.
+func newMetadataHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	return &http.Client{Timeout: defaultMetadataTimeout, Transport: transport}
+}
+
+// jwtExpiry reads the exp claim of a JWT without verifying it, for sources that return a bare
+// token. The Databricks token endpoint performs the actual validation.
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
+
+// EnvTokenProvider supplies the subject token from an environment variable, e.g. one populated
+// by a sidecar or CI runner.
+type EnvTokenProvider struct {
+	name string
+}
+
+// NewEnvTokenProvider returns a provider reading the variable name. The variable is checked once
+// so a missing token fails at Start rather than on the first export.
+func NewEnvTokenProvider(name string) (*EnvTokenProvider, error) {
+	p := &EnvTokenProvider{name: name}
+	if _, err := p.SubjectToken(context.Background()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SubjectToken returns the current value of the environment variable, trimmed of whitespace.
+func (p *EnvTokenProvider) SubjectToken(_ context.Context) (string, error) {
+	token := strings.TrimSpace(os.Getenv(p.name))
+	if token == "" {
+		return "", fmt.Errorf("environment variable %s is not set", p.name)
+	}
+	return token, nil
+}
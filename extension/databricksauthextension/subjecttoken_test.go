@@ -0,0 +1,136 @@
+package databricksauthextension
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TestNewSubjectTokenSource verifies subject_token_source.type selects the registered source.
+func TestNewSubjectTokenSource(t *testing.T) {
+	old := newAWSProvider
+	newAWSProvider = func(_ context.Context, _ *Config, _ *zap.Logger) (SubjectTokenSource, error) {
+		return &mockSubjectTokenSource{token: "aws-tok"}, nil
+	}
+	defer func() { newAWSProvider = old }()
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("file-tok"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SUBJECT_TOKEN", "env-tok")
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{name: "default is aws_sts", cfg: Config{}, want: "aws-tok"},
+		{name: "aws_sts", cfg: Config{SubjectTokenSource: SubjectTokenSourceConfig{Type: sourceAWSSTS}}, want: "aws-tok"},
+		{name: "file", cfg: Config{SubjectTokenSource: SubjectTokenSourceConfig{Type: sourceFile, File: FileSourceConfig{Path: path}}}, want: "file-tok"},
+		{name: "service_account_token_file shorthand", cfg: Config{ServiceAccountTokenFile: path}, want: "file-tok"},
+		{name: "env", cfg: Config{SubjectTokenSource: SubjectTokenSourceConfig{Type: sourceEnv, Env: EnvSourceConfig{Name: "TEST_SUBJECT_TOKEN"}}}, want: "env-tok"},
+		{name: "exec", cfg: Config{SubjectTokenSource: SubjectTokenSourceConfig{Type: sourceExec, Exec: ExecSourceConfig{Command: "echo", Args: []string{"exec-tok"}}}}, want: "exec-tok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := newSubjectTokenSource(context.Background(), &tt.cfg, zap.NewNop())
+			if err != nil {
+				t.Fatalf("newSubjectTokenSource: %v", err)
+			}
+			got, err := src.SubjectToken(context.Background())
+			if err != nil {
+				t.Fatalf("SubjectToken: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SubjectToken = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNewSubjectTokenSource_Error verifies construction failures name the source type.
+func TestNewSubjectTokenSource_Error(t *testing.T) {
+	old := newAWSProvider
+	newAWSProvider = func(_ context.Context, _ *Config, _ *zap.Logger) (SubjectTokenSource, error) {
+		return nil, fmt.Errorf("no credentials")
+	}
+	defer func() { newAWSProvider = old }()
+
+	if _, err := newSubjectTokenSource(context.Background(), &Config{}, zap.NewNop()); err == nil {
+		t.Error("expected error when AWS provider fails, got nil")
+	}
+	cfg := &Config{SubjectTokenSource: SubjectTokenSourceConfig{Type: sourceEnv, Env: EnvSourceConfig{Name: "TEST_SUBJECT_TOKEN_UNSET"}}}
+	if _, err := newSubjectTokenSource(context.Background(), cfg, zap.NewNop()); err == nil {
+		t.Error("expected error for unset environment variable, got nil")
+	}
+}
+
+// TestEnvTokenProvider verifies the variable is read on every call and trimmed.
+func TestEnvTokenProvider(t *testing.T) {
+	t.Setenv("TEST_SUBJECT_TOKEN", " env-tok-1\n")
+	p, err := NewEnvTokenProvider("TEST_SUBJECT_TOKEN")
+	if err != nil {
+		t.Fatalf("NewEnvTokenProvider: %v", err)
+	}
+	if got, _ := p.SubjectToken(context.Background()); got != "env-tok-1" {
+		t.Errorf("SubjectToken = %q, want env-tok-1", got)
+	}
+	t.Setenv("TEST_SUBJECT_TOKEN", "env-tok-2")
+	if got, _ := p.SubjectToken(context.Background()); got != "env-tok-2" {
+		t.Errorf("SubjectToken after update = %q, want env-tok-2", got)
+	}
+}
+
+// TestJWTExpiry verifies the exp claim is read and malformed tokens are rejected.
+func TestJWTExpiry(t *testing.T) {
+	want := time.Unix(time.Now().Add(time.Hour).Unix(), 0)
+	if got, ok := jwtExpiry(testJWT(want)); !ok || !got.Equal(want) {
+		t.Errorf("jwtExpiry = %v, %v; want %v, true", got, ok, want)
+	}
+	for _, token := range []string{"", "opaque-token", "a.!!!.c", "a.e30.c"} {
+		if _, ok := jwtExpiry(token); ok {
+			t.Errorf("jwtExpiry(%q) ok = true, want false", token)
+		}
+	}
+}
+
+// TestCachedSubjectToken verifies tokens are reused until the expiry buffer, tokens without an
+// expiry are refetched, and errors are not cached.
+func TestCachedSubjectToken(t *testing.T) {
+	var c cachedSubjectToken
+	calls := 0
+	fetch := func(token string, expiry time.Time, err error) func() (string, time.Time, error) {
+		return func() (string, time.Time, error) {
+			calls++
+			return token, expiry, err
+		}
+	}
+
+	if _, err := c.get(fetch("", time.Time{}, fmt.Errorf("boom"))); err == nil {
+		t.Fatal("expected fetch error")
+	}
+	for range 2 {
+		if got, _ := c.get(fetch("long", time.Now().Add(time.Hour), nil)); got != "long" {
+			t.Errorf("token = %q, want long", got)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("fetches = %d, want 2 (error, then one cached token)", calls)
+	}
+
+	c = cachedSubjectToken{}
+	calls = 0
+	for range 2 {
+		_, _ = c.get(fetch("near-expiry", time.Now().Add(subjectTokenExpiryBuffer/2), nil))
+		_, _ = c.get(fetch("no-expiry", time.Time{}, nil))
+	}
+	if calls != 4 {
+		t.Errorf("fetches = %d, want 4 (nothing reusable cached)", calls)
+	}
+}
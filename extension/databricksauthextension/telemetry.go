@@ -0,0 +1,201 @@
+package databricksauthextension
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+const meterScope = "github.com/NixM0nk3y/otel-collector-aws-databricks-auth/extension/databricksauthextension"
+
+// Error classes recorded on otelcol_databricksauth_token_exchange_failures.
+const (
+	errorClassSubjectToken    = "subject_token"    // the subject token source (AWS STS, file, exec, ...) failed
+	errorClassNetwork         = "network"          // connection error talking to the token endpoint
+	errorClassThrottled       = "throttled"        // 429 from the token endpoint
+	errorClassServer          = "server_error"     // 5xx from the token endpoint
+	errorClassClient          = "client_error"     // other non-200, e.g. 400/401 invalid_client
+	errorClassInvalidResponse = "invalid_response" // 200 with an unusable body
+	errorClassCanceled        = "canceled"         // caller context cancelled or timed out
+)
+
+// Outcomes recorded on otelcol_databricksauth_unauthorized_replays.
+const (
+	replayOutcomeRecovered         = "recovered"          // replay with a fresh token succeeded
+	replayOutcomeStillUnauthorized = "still_unauthorized" // replay was rejected again
+	replayOutcomeRefreshFailed     = "refresh_failed"     // no fresh token could be obtained
+	replayOutcomeNotReplayable     = "not_replayable"     // request body cannot be replayed (no GetBody)
+	replayOutcomeError             = "error"              // replay failed at the transport level
+	replayOutcomeRateLimited       = "rate_limited"       // a 401 already forced a refresh within forcedRefreshWindow
+)
+
+var (
+	errorClassKey = attribute.Key("error.class")
+	outcomeKey    = attribute.Key("outcome")
+	sourceKey     = attribute.Key("source") // subject_token_source type
+)
+
+// tokenTelemetry holds the extension's internal metrics. A nil *tokenTelemetry records nothing.
+type tokenTelemetry struct {
+	exchangeAttempts  metric.Int64Counter
+	exchangeSuccesses metric.Int64Counter
+	exchangeFailures  metric.Int64Counter
+	exchangeDuration  metric.Float64Histogram
+	subjectDuration   metric.Float64Histogram
+	cacheHits         metric.Int64Counter
+	cacheMisses       metric.Int64Counter
+	graceServed       metric.Int64Counter
+	replays           metric.Int64Counter
+
+	registration metric.Registration
+}
+
+// newTokenTelemetry creates the instruments on mp. secondsUntilExpiry backs the expiry gauge and
+// reports false when no token is cached.
+func newTokenTelemetry(mp metric.MeterProvider, secondsUntilExpiry func() (float64, bool)) (*tokenTelemetry, error) {
+	if mp == nil {
+		mp = noop.NewMeterProvider()
+	}
+	meter := mp.Meter(meterScope)
+	t := &tokenTelemetry{}
+
+	var errs, err error
+	t.exchangeAttempts, err = meter.Int64Counter("otelcol_databricksauth_token_exchange_attempts",
+		metric.WithDescription("Number of requests to the Databricks token endpoint, including retries."), metric.WithUnit("{requests}"))
+	errs = errors.Join(errs, err)
+	t.exchangeSuccesses, err = meter.Int64Counter("otelcol_databricksauth_token_exchange_successes",
+		metric.WithDescription("Number of Databricks token exchanges that returned a token."), metric.WithUnit("{exchanges}"))
+	errs = errors.Join(errs, err)
+	t.exchangeFailures, err = meter.Int64Counter("otelcol_databricksauth_token_exchange_failures",
+		metric.WithDescription("Number of failed Databricks token exchanges, by error class."), metric.WithUnit("{exchanges}"))
+	errs = errors.Join(errs, err)
+	t.exchangeDuration, err = meter.Float64Histogram("otelcol_databricksauth_token_exchange_duration",
+		metric.WithDescription("Duration of Databricks token exchanges, including retries."), metric.WithUnit("s"))
+	errs = errors.Join(errs, err)
+	t.subjectDuration, err = meter.Float64Histogram("otelcol_databricksauth_subject_token_duration",
+		metric.WithDescription("Duration of subject token fetches, by subject token source."), metric.WithUnit("s"))
+	errs = errors.Join(errs, err)
+	t.cacheHits, err = meter.Int64Counter("otelcol_databricksauth_token_cache_hits",
+		metric.WithDescription("Number of token requests served from the cache."), metric.WithUnit("{requests}"))
+	errs = errors.Join(errs, err)
+	t.cacheMisses, err = meter.Int64Counter("otelcol_databricksauth_token_cache_misses",
+		metric.WithDescription("Number of token requests that required a refresh."), metric.WithUnit("{requests}"))
+	errs = errors.Join(errs, err)
+	t.graceServed, err = meter.Int64Counter("otelcol_databricksauth_grace_tokens_served",
+		metric.WithDescription("Number of token requests served the cached token after a failed refresh (grace_mode)."), metric.WithUnit("{requests}"))
+	errs = errors.Join(errs, err)
+	t.replays, err = meter.Int64Counter("otelcol_databricksauth_unauthorized_replays",
+		metric.WithDescription("Number of requests replayed with a fresh token after a 401, by outcome."), metric.WithUnit("{requests}"))
+	errs = errors.Join(errs, err)
+
+	expiry, err := meter.Float64ObservableGauge("otelcol_databricksauth_token_expiry_seconds",
+		metric.WithDescription("Seconds until the cached Databricks token expires."), metric.WithUnit("s"))
+	errs = errors.Join(errs, err)
+	if errs != nil {
+		return nil, errs
+	}
+	t.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		if secs, ok := secondsUntilExpiry(); ok {
+			o.ObserveFloat64(expiry, secs)
+		}
+		return nil
+	}, expiry)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// shutdown unregisters the expiry gauge callback.
+func (t *tokenTelemetry) shutdown() error {
+	if t == nil || t.registration == nil {
+		return nil
+	}
+	return t.registration.Unregister()
+}
+
+func (t *tokenTelemetry) recordCacheHit(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.cacheHits.Add(ctx, 1)
+}
+
+func (t *tokenTelemetry) recordCacheMiss(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.cacheMisses.Add(ctx, 1)
+}
+
+func (t *tokenTelemetry) recordGraceServed(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.graceServed.Add(context.WithoutCancel(ctx), 1)
+}
+
+func (t *tokenTelemetry) recordUnauthorizedReplay(ctx context.Context, outcome string) {
+	if t == nil {
+		return
+	}
+	t.replays.Add(context.WithoutCancel(ctx), 1, metric.WithAttributes(outcomeKey.String(outcome)))
+}
+
+func (t *tokenTelemetry) recordSubjectToken(ctx context.Context, start time.Time, source string) {
+	if t == nil {
+		return
+	}
+	t.subjectDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(sourceKey.String(source)))
+}
+
+// recordExchangeAttempt records one request to the token endpoint; retries count separately.
+func (t *tokenTelemetry) recordExchangeAttempt(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.exchangeAttempts.Add(context.WithoutCancel(ctx), 1)
+}
+
+// recordExchange records one completed exchange. class is ignored when err is nil.
+func (t *tokenTelemetry) recordExchange(ctx context.Context, start time.Time, class string, err error) {
+	if t == nil {
+		return
+	}
+	// Record even if the caller's context is cancelled.
+	ctx = context.WithoutCancel(ctx)
+	t.exchangeDuration.Record(ctx, time.Since(start).Seconds())
+	if err == nil {
+		t.exchangeSuccesses.Add(ctx, 1)
+		return
+	}
+	t.exchangeFailures.Add(ctx, 1, metric.WithAttributes(errorClassKey.String(class)))
+}
+
+// classifyEndpointError maps a token endpoint failure to an error class.
+func classifyEndpointError(err error) string {
+	var epErr *tokenEndpointError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return errorClassCanceled
+	case errors.As(err, &epErr):
+		switch {
+		case epErr.StatusCode == http.StatusTooManyRequests:
+			return errorClassThrottled
+		case epErr.StatusCode >= http.StatusInternalServerError:
+			return errorClassServer
+		default:
+			return errorClassClient
+		}
+	}
+	var rerr *retryableError
+	if errors.As(err, &rerr) {
+		return errorClassNetwork
+	}
+	return errorClassInvalidResponse
+}
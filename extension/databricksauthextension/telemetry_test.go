@@ -0,0 +1,203 @@
+package databricksauthextension
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// collectMetrics reads all metrics from reader keyed by instrument name.
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	out := map[string]metricdata.Metrics{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			out[m.Name] = m
+		}
+	}
+	return out
+}
+
+// sumValue returns the int64 sum value for the data point matching attrs.
+func sumValue(t *testing.T, m metricdata.Metrics, attrs ...attribute.KeyValue) int64 {
+	t.Helper()
+	sum, ok := m.Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("%s: expected Sum[int64], got %T", m.Name, m.Data)
+	}
+	want := attribute.NewSet(attrs...)
+	for _, dp := range sum.DataPoints {
+		if dp.Attributes.Equals(&want) {
+			return dp.Value
+		}
+	}
+	return 0
+}
+
+func newTestTelemetry(t *testing.T, cache *tokenCache) *sdkmetric.ManualReader {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+	tel, err := newTokenTelemetry(mp, cache.secondsUntilExpiry)
+	if err != nil {
+		t.Fatalf("newTokenTelemetry: %v", err)
+	}
+	cache.telemetry = tel
+	return reader
+}
+
+// TestTelemetry_SuccessfulExchangeAndCacheHit verifies exchange, subject token, cache and expiry metrics on the happy path.
+func TestTelemetry_SuccessfulExchangeAndCacheHit(t *testing.T) {
+	server := createMockOIDCServer(t, "tok", 3600)
+	defer server.Close()
+
+	cache := newTestTokenCache(server.URL, &mockSubjectTokenSource{token: "aws-token"})
+	cache.sourceType = sourceAWSSTS
+	reader := newTestTelemetry(t, cache)
+
+	for range 2 {
+		if _, err := cache.GetToken(context.Background()); err != nil {
+			t.Fatalf("GetToken: %v", err)
+		}
+	}
+
+	metrics := collectMetrics(t, reader)
+	checks := map[string]int64{
+		"otelcol_databricksauth_token_exchange_attempts":  1,
+		"otelcol_databricksauth_token_exchange_successes": 1,
+		"otelcol_databricksauth_token_cache_hits":         1,
+		"otelcol_databricksauth_token_cache_misses":       1,
+	}
+	for name, want := range checks {
+		m, ok := metrics[name]
+		if !ok {
+			t.Errorf("metric %s not recorded", name)
+			continue
+		}
+		if got := sumValue(t, m); got != want {
+			t.Errorf("%s = %d, want %d", name, got, want)
+		}
+	}
+	if _, ok := metrics["otelcol_databricksauth_token_exchange_duration"]; !ok {
+		t.Error("metric otelcol_databricksauth_token_exchange_duration not recorded")
+	}
+	subject, ok := metrics["otelcol_databricksauth_subject_token_duration"].Data.(metricdata.Histogram[float64])
+	if !ok || len(subject.DataPoints) != 1 {
+		t.Fatalf("subject token duration = %+v, want one data point", metrics["otelcol_databricksauth_subject_token_duration"].Data)
+	}
+	if source, _ := subject.DataPoints[0].Attributes.Value(sourceKey); source.AsString() != sourceAWSSTS {
+		t.Errorf("subject token duration source = %q, want %q", source.AsString(), sourceAWSSTS)
+	}
+
+	gauge, ok := metrics["otelcol_databricksauth_token_expiry_seconds"].Data.(metricdata.Gauge[float64])
+	if !ok || len(gauge.DataPoints) != 1 {
+		t.Fatalf("expected one expiry gauge data point, got %+v", metrics["otelcol_databricksauth_token_expiry_seconds"].Data)
+	}
+	if v := gauge.DataPoints[0].Value; v <= 3500 || v > 3600 {
+		t.Errorf("token_expiry_seconds = %v, want ~3600", v)
+	}
+}
+
+// TestTelemetry_FailureClasses verifies failures are counted by error class.
+func TestTelemetry_FailureClasses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	cache := newTestTokenCache(server.URL, &mockSubjectTokenSource{token: "aws-token"})
+	reader := newTestTelemetry(t, cache)
+	if _, err := cache.GetToken(context.Background()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	cache.subjectSource = &mockSubjectTokenSource{err: fmt.Errorf("aws down")}
+	if _, err := cache.GetToken(context.Background()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	failures := collectMetrics(t, reader)["otelcol_databricksauth_token_exchange_failures"]
+	if got := sumValue(t, failures, errorClassKey.String(errorClassClient)); got != 1 {
+		t.Errorf("client_error failures = %d, want 1", got)
+	}
+	if got := sumValue(t, failures, errorClassKey.String(errorClassSubjectToken)); got != 1 {
+		t.Errorf("subject_token failures = %d, want 1", got)
+	}
+}
+
+// TestTelemetry_AttemptsCountRetries verifies every request to the token endpoint is counted as
+// an attempt, so retries show up next to the single success they led to.
+func TestTelemetry_AttemptsCountRetries(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if hits.Add(1) <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokenExchangeResponse{AccessToken: "tok", TokenType: "Bearer", ExpiresIn: 3600})
+	}))
+	defer server.Close()
+
+	cache := newTestTokenCache(server.URL, &mockSubjectTokenSource{token: "aws-token"})
+	cache.retry = retryPolicy{maxAttempts: 3, initialInterval: time.Millisecond, maxInterval: time.Millisecond}
+	reader := newTestTelemetry(t, cache)
+	if _, err := cache.GetToken(context.Background()); err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+
+	metrics := collectMetrics(t, reader)
+	if got := sumValue(t, metrics["otelcol_databricksauth_token_exchange_attempts"]); got != 3 {
+		t.Errorf("exchange attempts = %d, want 3", got)
+	}
+	if got := sumValue(t, metrics["otelcol_databricksauth_token_exchange_successes"]); got != 1 {
+		t.Errorf("exchange successes = %d, want 1", got)
+	}
+}
+
+func TestClassifyEndpointError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "429", err: &retryableError{err: &tokenEndpointError{StatusCode: http.StatusTooManyRequests}}, want: errorClassThrottled},
+		{name: "503", err: &retryableError{err: &tokenEndpointError{StatusCode: http.StatusServiceUnavailable}}, want: errorClassServer},
+		{name: "401", err: &tokenEndpointError{StatusCode: http.StatusUnauthorized, Code: "invalid_client"}, want: errorClassClient},
+		{name: "connection refused", err: &retryableError{err: errors.New("dial tcp: connection refused")}, want: errorClassNetwork},
+		{name: "cancelled", err: errors.Join(errors.New("boom"), context.Canceled), want: errorClassCanceled},
+		{name: "bad json", err: errors.New("failed to parse token exchange response"), want: errorClassInvalidResponse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyEndpointError(tt.err); got != tt.want {
+				t.Errorf("classifyEndpointError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTelemetry_NilIsNoop verifies a cache without telemetry records nothing and does not panic.
+func TestTelemetry_NilIsNoop(t *testing.T) {
+	var tel *tokenTelemetry
+	tel.recordCacheHit(context.Background())
+	tel.recordExchange(context.Background(), time.Now(), errorClassClient, errors.New("x"))
+	if err := tel.shutdown(); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}
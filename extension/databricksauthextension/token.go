@@ -3,86 +3,150 @@ package databricksauthextension
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"golang.org/x/sync/singleflight"
 )
 
 const (
-	oidcTokenEndpoint      = "/oidc/v1/token"                                    // #nosec G101 -- URL path, not a credential
-	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"   // #nosec G101 -- OAuth 2.0 grant type URI (RFC 8693)
-	tokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"              // #nosec G101 -- OAuth 2.0 token type URI (RFC 8693)
+	oidcTokenEndpoint      = "/oidc/v1/token"                                  // #nosec G101 -- URL path, not a credential
+	accountTokenEndpoint   = "/oidc/accounts/%s/v1/token"                      // #nosec G101 -- URL path template, not a credential
+	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange" // #nosec G101 -- OAuth 2.0 grant type URI (RFC 8693)
+	tokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"            // #nosec G101 -- OAuth 2.0 token type URI (RFC 8693)
+	tokenTypePrefix        = "urn:ietf:params:oauth:token-type:"               // #nosec G101 -- OAuth 2.0 token type URI namespace (RFC 8693)
+	grantTypeClientCreds   = "client_credentials"                              // #nosec G101 -- OAuth 2.0 grant type (RFC 6749)
 	defaultTokenTTL        = 1 * time.Hour
+	defaultScope           = "all-apis"
+	defaultAccountHost     = "https://accounts.cloud.databricks.com"
+	defaultSTSAudience     = "AwsTokenExchange"
+	defaultSTSSigningAlg   = "RS256"
+	defaultRoleSessionName = "otel-databricksauth"
 )
 
-// AWSTokenProvider abstracts AWS identity token acquisition — mockable in tests.
-type AWSTokenProvider interface {
-	GetWebIdentityToken(ctx context.Context) (string, error)
+// Background refresher pacing. Variables so tests can shorten them.
+var (
+	minRefreshInterval   = 10 * time.Second // floor between proactive refreshes, guards against short-lived tokens
+	refreshRetryInterval = 30 * time.Second // wait after a failed proactive refresh
+	forcedRefreshWindow  = 30 * time.Second // at most one refresh forced by a 401 per window
+	logSampleInterval    = time.Minute      // each distinct log message is emitted at most once per interval
+)
+
+// STSTokenProviderOptions customises the GetWebIdentityToken request. Zero values use defaults.
+type STSTokenProviderOptions struct {
+	Audience         []string // default: ["AwsTokenExchange"]
+	SigningAlgorithm string   // default: "RS256"
+	Endpoint         string   // STS endpoint override, e.g. a VPC or FIPS endpoint; default: SDK resolution
+
+	// Optional role to assume (via the ambient credentials) before requesting the web identity
+	// token, making the assumed role the JWT subject.
+	AssumeRoleARN string
+	ExternalID    string
+	SessionName   string        // default: "otel-databricksauth"
+	Duration      time.Duration // default: SDK default (15m)
 }
 
-// STSTokenProvider is the ECS/EC2 concrete implementation using aws-sdk-go-v2.
+// STSTokenProvider is the default SubjectTokenSource: an AWS web identity token from STS (ECS/EC2),
+// using aws-sdk-go-v2.
 type STSTokenProvider struct {
-	stsClient *sts.Client
+	stsClient  *sts.Client
+	logger     *zap.Logger
+	audience   []string
+	signingAlg string
 
-	mu          sync.RWMutex
-	cachedToken string
-	tokenExpiry time.Time
+	cache cachedSubjectToken
 }
 
-// NewSTSTokenProvider creates an STSTokenProvider by loading the default AWS config.
-func NewSTSTokenProvider(ctx context.Context) (*STSTokenProvider, error) {
-	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+// NewSTSTokenProvider creates an STSTokenProvider by loading the default AWS config with optional overrides.
+// A nil logger disables logging.
+func NewSTSTokenProvider(ctx context.Context, logger *zap.Logger, opts STSTokenProviderOptions, optFns ...func(*awsconfig.LoadOptions) error) (*STSTokenProvider, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
-	return &STSTokenProvider{
-		stsClient: sts.NewFromConfig(cfg),
-	}, nil
-}
-
-// GetWebIdentityToken returns an AWS OIDC web identity token, caching it until near expiry.
-func (p *STSTokenProvider) GetWebIdentityToken(ctx context.Context) (string, error) {
-	const expiryBuffer = 30 * time.Second
-
-	p.mu.RLock()
-	if p.cachedToken != "" && time.Now().Before(p.tokenExpiry.Add(-expiryBuffer)) {
-		token := p.cachedToken
-		p.mu.RUnlock()
-		return token, nil
+	if logger == nil {
+		logger = zap.NewNop()
 	}
-	p.mu.RUnlock()
-
-	audience := "AwsTokenExchange"
-	signingAlg := "RS256"
-	output, err := p.stsClient.GetWebIdentityToken(ctx, &sts.GetWebIdentityTokenInput{
-		Audience:         []string{audience},
-		SigningAlgorithm: &signingAlg,
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to get web identity token from STS: %w", err)
+	withEndpoint := func(o *sts.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+		}
 	}
-	if output.WebIdentityToken == nil || *output.WebIdentityToken == "" {
-		return "", fmt.Errorf("STS returned empty token")
+	if opts.AssumeRoleARN != "" {
+		sessionName := opts.SessionName
+		if sessionName == "" {
+			sessionName = defaultRoleSessionName
+		}
+		// The ambient credentials assume the target role; its credentials then sign GetWebIdentityToken.
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(
+			sts.NewFromConfig(cfg, withEndpoint), opts.AssumeRoleARN,
+			func(o *stscreds.AssumeRoleOptions) {
+				o.RoleSessionName = sessionName
+				if opts.ExternalID != "" {
+					o.ExternalID = aws.String(opts.ExternalID)
+				}
+				if opts.Duration > 0 {
+					o.Duration = opts.Duration
+				}
+			}))
+		logger.Debug("Assuming role before web identity token issuance", zap.String("role_arn", opts.AssumeRoleARN))
 	}
-
-	p.mu.Lock()
-	p.cachedToken = *output.WebIdentityToken
-	if output.Expiration != nil {
-		p.tokenExpiry = *output.Expiration
-	} else {
-		p.tokenExpiry = time.Now().Add(5 * time.Minute)
+	p := &STSTokenProvider{
+		stsClient:  sts.NewFromConfig(cfg, withEndpoint),
+		logger:     logger,
+		audience:   []string{defaultSTSAudience},
+		signingAlg: defaultSTSSigningAlg,
 	}
-	p.mu.Unlock()
+	if len(opts.Audience) > 0 {
+		p.audience = opts.Audience
+	}
+	if opts.SigningAlgorithm != "" {
+		p.signingAlg = opts.SigningAlgorithm
+	}
+	return p, nil
+}
 
-	return *output.WebIdentityToken, nil
+// SubjectToken implements SubjectTokenSource.
+func (p *STSTokenProvider) SubjectToken(ctx context.Context) (string, error) {
+	return p.GetWebIdentityToken(ctx)
+}
+
+// GetWebIdentityToken returns an AWS OIDC web identity token, caching it until near expiry.
+func (p *STSTokenProvider) GetWebIdentityToken(ctx context.Context) (string, error) {
+	return p.cache.get(func() (string, time.Time, error) {
+		output, err := p.stsClient.GetWebIdentityToken(ctx, &sts.GetWebIdentityTokenInput{
+			Audience:         p.audience,
+			SigningAlgorithm: aws.String(p.signingAlg),
+		})
+		if err != nil {
+			p.logger.Warn("STS GetWebIdentityToken failed", zap.String("region", p.stsClient.Options().Region), zap.Error(err))
+			return "", time.Time{}, fmt.Errorf("failed to get web identity token from STS: %w", err)
+		}
+		if output.WebIdentityToken == nil || *output.WebIdentityToken == "" {
+			p.logger.Warn("STS GetWebIdentityToken returned an empty token")
+			return "", time.Time{}, fmt.Errorf("STS returned empty token")
+		}
+		expiry := time.Now().Add(5 * time.Minute)
+		if output.Expiration != nil {
+			expiry = *output.Expiration
+		}
+		p.logger.Debug("Fetched AWS web identity token", zap.Time("expiry", expiry))
+		return *output.WebIdentityToken, expiry, nil
+	})
 }
 
 // tokenExchangeResponse is the success response from the OIDC token endpoint.
@@ -98,17 +162,76 @@ type tokenExchangeErrorResponse struct {
 	ErrorDescription string `json:"error_description"`
 }
 
+// tokenEndpointError is a non-200 response from the OIDC token endpoint.
+type tokenEndpointError struct {
+	StatusCode  int
+	Code        string // OAuth error code, e.g. invalid_client; empty if the body was not an error response
+	Description string
+}
+
+// permanentOAuthErrors are token endpoint error codes only a collector config change can fix.
+// Others, such as unauthorized_client or a federation policy mismatch, clear once the service
+// principal or policy is fixed in Databricks, which the background refresher picks up.
+var permanentOAuthErrors = map[string]bool{
+	"invalid_client": true,
+	"invalid_scope":  true,
+}
+
+// isPermanentAuthError reports whether err indicates collector misconfiguration (unknown client,
+// wrong secret, invalid scopes) rather than a failure that can clear without a restart.
+func isPermanentAuthError(err error) bool {
+	var epErr *tokenEndpointError
+	if !errors.As(err, &epErr) || isRetryableStatus(epErr.StatusCode) {
+		return false
+	}
+	return permanentOAuthErrors[epErr.Code]
+}
+
+// databricksSideAuthError returns the token endpoint error when err is a rejection fixed in
+// Databricks rather than in the collector config: unauthorized_client or a federation policy
+// mismatch. Nil otherwise.
+func databricksSideAuthError(err error) *tokenEndpointError {
+	var epErr *tokenEndpointError
+	if !errors.As(err, &epErr) || isRetryableStatus(epErr.StatusCode) {
+		return nil
+	}
+	if epErr.Code == "unauthorized_client" || strings.Contains(strings.ToLower(epErr.Description), "federation policy") {
+		return epErr
+	}
+	return nil
+}
+
+func (e *tokenEndpointError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("token exchange failed (%s): %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("token exchange failed with status %d", e.StatusCode)
+}
+
 // tokenCache holds a Databricks access token with lazy refresh and singleflight coalescing.
+// When clientSecret is set it uses the client_credentials grant; otherwise it exchanges an AWS token.
 type tokenCache struct {
-	workspaceURL string
-	spClientID   string
-	expiryBuffer time.Duration
-	awsProvider  AWSTokenProvider
-	httpClient   *http.Client
+	workspaceURL  string
+	tokenURL      string        // default: workspaceURL + oidcTokenEndpoint
+	discovered    *oidcMetadata // fetched once at Start when oidc_discovery is set; overrides tokenURL
+	spClientID    string
+	clientSecret  configopaque.String
+	expiryBuffer  time.Duration
+	subjectSource SubjectTokenSource // nil in M2M mode
+	sourceType    string             // subject_token_source type, recorded on subject token metrics
+	requestParams url.Values         // override/extend the token request form, e.g. scope, audience
+	httpClient    *http.Client
+	retry         retryPolicy
+	graceMode     bool            // serve the cached token until hard expiry when a refresh fails
+	logger        *zap.Logger     // should be rate limited, see newRateLimitedLogger; never logs token values
+	telemetry     *tokenTelemetry // nil disables internal metrics
+	onRefresh     func(error)     // optional; called with the outcome of every refresh attempt
 
 	mu          sync.RWMutex
 	cachedToken string
 	tokenExpiry time.Time
+	lastFailure time.Time // time of the most recent failed refresh
+	lastForced  time.Time // time of the most recent invalidation after a 401
 	sfGroup     singleflight.Group
 }
 
@@ -119,9 +242,11 @@ func (c *tokenCache) GetToken(ctx context.Context) (string, error) {
 	if c.cachedToken != "" && time.Now().Before(c.tokenExpiry.Add(-c.expiryBuffer)) {
 		token := c.cachedToken
 		c.mu.RUnlock()
+		c.telemetry.recordCacheHit(ctx)
 		return token, nil
 	}
 	c.mu.RUnlock()
+	c.telemetry.recordCacheMiss(ctx)
 
 	// Slow path: use singleflight to coalesce concurrent refreshes.
 	result, err, _ := c.sfGroup.Do("token", func() (interface{}, error) {
@@ -134,18 +259,40 @@ func (c *tokenCache) GetToken(ctx context.Context) (string, error) {
 		}
 		c.mu.RUnlock()
 
-		token, expiresIn, err := c.exchangeToken(ctx)
-		if err != nil {
-			return "", err
+		// In grace mode, don't stall every request on a refresh that just failed; the
+		// background refresher keeps retrying while the still-valid token is served.
+		if c.graceMode {
+			c.mu.RLock()
+			recentFailure := time.Since(c.lastFailure) < refreshRetryInterval
+			c.mu.RUnlock()
+			if token, ok := c.staleToken(); ok && recentFailure {
+				c.telemetry.recordGraceServed(ctx)
+				return token, nil
+			}
 		}
 
-		expiry := time.Now().Add(time.Duration(expiresIn) * time.Second)
-		c.mu.Lock()
-		c.cachedToken = token
-		c.tokenExpiry = expiry
-		c.mu.Unlock()
+		token, err := c.refresh(ctx)
+		if err != nil && c.graceMode {
+			if stale, ok := c.staleToken(); ok {
+				c.logger.Warn("Serving cached Databricks token until it expires after refresh failure",
+					append(c.logFields(), zap.Error(err))...)
+				c.telemetry.recordGraceServed(ctx)
+				return stale, nil
+			}
+		}
+		return token, err
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.(string), nil
+}
 
-		return token, nil
+// Refresh unconditionally exchanges a new token, coalescing with any in-flight refresh.
+// Used by the background refresher to renew ahead of expiry.
+func (c *tokenCache) Refresh(ctx context.Context) (string, error) {
+	result, err, _ := c.sfGroup.Do("token", func() (interface{}, error) {
+		return c.refresh(ctx)
 	})
 	if err != nil {
 		return "", err
@@ -153,44 +300,216 @@ func (c *tokenCache) GetToken(ctx context.Context) (string, error) {
 	return result.(string), nil
 }
 
-// exchangeToken performs the OAuth 2.0 Token Exchange (RFC 8693) against the Databricks OIDC endpoint.
-func (c *tokenCache) exchangeToken(ctx context.Context) (string, int, error) {
-	awsToken, err := c.awsProvider.GetWebIdentityToken(ctx)
+// refresh performs a token exchange and stores the result. Callers must hold the singleflight slot.
+func (c *tokenCache) refresh(ctx context.Context) (string, error) {
+	start := time.Now()
+	token, expiresIn, err := c.exchangeToken(ctx)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to get AWS token: %w", err)
+		c.mu.Lock()
+		c.lastFailure = time.Now()
+		c.mu.Unlock()
+
+		fields := append(c.logFields(), zap.Duration("duration", time.Since(start)), zap.Error(err))
+		var epErr *tokenEndpointError
+		if errors.As(err, &epErr) {
+			fields = append(fields, zap.Int("status_code", epErr.StatusCode), zap.String("oauth_error", epErr.Code))
+		}
+		c.logger.Warn("Databricks token refresh failed", fields...)
+		if c.onRefresh != nil {
+			c.onRefresh(err)
+		}
+		return "", err
+	}
+
+	expiry := time.Now().Add(time.Duration(expiresIn) * time.Second)
+	c.mu.Lock()
+	c.cachedToken = token
+	c.tokenExpiry = expiry
+	c.mu.Unlock()
+
+	c.logger.Info("Refreshed Databricks token",
+		append(c.logFields(), zap.Time("expiry", expiry), zap.Duration("duration", time.Since(start)))...)
+	if c.onRefresh != nil {
+		c.onRefresh(nil)
+	}
+	return token, nil
+}
+
+// logFields identifies the cache in log entries. Secrets and tokens are deliberately excluded.
+func (c *tokenCache) logFields() []zap.Field {
+	mode := "federation"
+	if c.clientSecret != "" {
+		mode = "m2m"
+	}
+	host := c.workspaceURL
+	if u, err := url.Parse(c.workspaceURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return []zap.Field{
+		zap.String("mode", mode),
+		zap.String("workspace_host", host),
+		zap.String("client_id", c.spClientID),
+	}
+}
+
+// newRateLimitedLogger returns a logger that emits each distinct message at most once per interval,
+// so a storm of failing requests does not flood the logs.
+func newRateLimitedLogger(logger *zap.Logger, interval time.Duration) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(core, interval, 1, 0)
+	}))
+}
+
+// invalidate drops the cached token if it is still the given (rejected) token, so the next GetToken
+// performs a fresh exchange. Tokens refreshed by a concurrent caller are kept. Only one token is
+// dropped per forcedRefreshWindow, so a principal Databricks keeps rejecting does not turn every
+// export into a token exchange; invalidate reports false when it kept the rejected token.
+func (c *tokenCache) invalidate(token string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cachedToken != token {
+		return true
+	}
+	if time.Since(c.lastForced) < forcedRefreshWindow {
+		return false
+	}
+	c.lastForced = time.Now()
+	c.cachedToken = ""
+	c.tokenExpiry = time.Time{}
+	return true
+}
+
+// staleToken returns the cached token if it has not yet reached its hard expiry,
+// ignoring expiryBuffer.
+func (c *tokenCache) staleToken() (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.cachedToken != "" && time.Now().Before(c.tokenExpiry) {
+		return c.cachedToken, true
+	}
+	return "", false
+}
+
+// secondsUntilExpiry reports the remaining lifetime of the cached token, or false if none is cached.
+func (c *tokenCache) secondsUntilExpiry() (float64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.cachedToken == "" {
+		return 0, false
+	}
+	return time.Until(c.tokenExpiry).Seconds(), true
+}
+
+// nextRefreshIn returns how long to wait before proactively renewing the cached token:
+// zero when nothing is cached, otherwise until expiryBuffer plus up to 20% jitter before expiry.
+func (c *tokenCache) nextRefreshIn() time.Duration {
+	c.mu.RLock()
+	token, expiry := c.cachedToken, c.tokenExpiry
+	c.mu.RUnlock()
+	if token == "" {
+		return 0
 	}
 
-	tokenURL := c.workspaceURL + oidcTokenEndpoint
+	lead := c.expiryBuffer
+	if jitterMax := int64(c.expiryBuffer / 5); jitterMax > 0 {
+		lead += time.Duration(rand.Int64N(jitterMax)) // #nosec G404 -- jitter, not security-sensitive
+	}
+	return max(time.Until(expiry.Add(-lead)), minRefreshInterval)
+}
+
+// exchangeToken obtains a fresh Databricks access token from the OIDC endpoint, using the
+// client_credentials grant in M2M mode and the OAuth 2.0 Token Exchange (RFC 8693) otherwise.
+// Transient endpoint failures are retried according to c.retry.
+func (c *tokenCache) exchangeToken(ctx context.Context) (string, int, error) {
+	start := time.Now()
 	formData := url.Values{}
-	formData.Set("grant_type", grantTypeTokenExchange)
-	formData.Set("subject_token", awsToken)
-	formData.Set("subject_token_type", tokenTypeJWT)
-	formData.Set("client_id", c.spClientID)
-	formData.Set("scope", "all-apis")
+	if c.clientSecret != "" {
+		formData.Set("grant_type", grantTypeClientCreds)
+	} else {
+		subjectStart := time.Now()
+		subjectToken, err := c.subjectSource.SubjectToken(ctx)
+		c.telemetry.recordSubjectToken(ctx, subjectStart, c.sourceType)
+		if err != nil {
+			err = fmt.Errorf("failed to get subject token: %w", err)
+			c.telemetry.recordExchange(ctx, start, errorClassSubjectToken, err)
+			return "", 0, err
+		}
+		formData.Set("grant_type", grantTypeTokenExchange)
+		formData.Set("subject_token", subjectToken)
+		formData.Set("subject_token_type", tokenTypeJWT)
+		formData.Set("client_id", c.spClientID)
+	}
+	formData.Set("scope", defaultScope)
+	for k, v := range c.requestParams {
+		formData[k] = v
+	}
+
+	var (
+		token     string
+		expiresIn int
+	)
+	err := c.retry.do(ctx, func() error {
+		c.telemetry.recordExchangeAttempt(ctx)
+		var err error
+		token, expiresIn, err = c.postTokenRequest(ctx, formData)
+		return err
+	})
+	if err != nil {
+		c.telemetry.recordExchange(ctx, start, classifyEndpointError(err), err)
+		return "", 0, err
+	}
+	c.telemetry.recordExchange(ctx, start, "", nil)
+	return token, expiresIn, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(formData.Encode()))
+// tokenEndpoint returns the OIDC token URL requests are posted to.
+func (c *tokenCache) tokenEndpoint() string {
+	if c.discovered != nil {
+		return c.discovered.TokenEndpoint
+	}
+	if c.tokenURL != "" {
+		return c.tokenURL
+	}
+	return c.workspaceURL + oidcTokenEndpoint
+}
+
+// postTokenRequest makes a single POST to the OIDC token endpoint. Transient failures are
+// returned as *retryableError.
+func (c *tokenCache) postTokenRequest(ctx context.Context, formData url.Values) (string, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenEndpoint(), strings.NewReader(formData.Encode()))
 	if err != nil {
 		return "", 0, fmt.Errorf("failed to create token exchange request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.clientSecret != "" {
+		req.SetBasicAuth(c.spClientID, string(c.clientSecret))
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", 0, fmt.Errorf("token exchange request failed: %w", err)
+		err = fmt.Errorf("token exchange request failed: %w", err)
+		if ctx.Err() != nil {
+			return "", 0, err
+		}
+		return "", 0, &retryableError{err: err}
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to read token exchange response: %w", err)
+		return "", 0, &retryableError{err: fmt.Errorf("failed to read token exchange response: %w", err)}
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		epErr := &tokenEndpointError{StatusCode: resp.StatusCode}
 		var errResp tokenExchangeErrorResponse
 		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
-			return "", 0, fmt.Errorf("token exchange failed (%s): %s", errResp.Error, errResp.ErrorDescription)
+			epErr.Code, epErr.Description = errResp.Error, errResp.ErrorDescription
+		}
+		if isRetryableStatus(resp.StatusCode) {
+			return "", 0, &retryableError{err: epErr, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
 		}
-		return "", 0, fmt.Errorf("token exchange failed with status %d", resp.StatusCode)
+		return "", 0, epErr
 	}
 
 	var tokenResp tokenExchangeResponse
@@ -6,20 +6,29 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
-// mockAWSTokenProvider is a mock for AWSTokenProvider, usable in tests.
-type mockAWSTokenProvider struct {
+// mockSubjectTokenSource is a mock for SubjectTokenSource, usable in tests.
+type mockSubjectTokenSource struct {
 	token     string
 	err       error
 	callCount atomic.Int32
 }
 
-func (m *mockAWSTokenProvider) GetWebIdentityToken(_ context.Context) (string, error) {
+func (m *mockSubjectTokenSource) SubjectToken(_ context.Context) (string, error) {
 	m.callCount.Add(1)
 	return m.token, m.err
 }
@@ -59,13 +68,14 @@ func createMockOIDCServerWithCounter(t *testing.T, accessToken string, expiresIn
 	}))
 }
 
-func newTestTokenCache(workspaceURL string, provider AWSTokenProvider) *tokenCache {
+func newTestTokenCache(workspaceURL string, provider SubjectTokenSource) *tokenCache {
 	return &tokenCache{
-		workspaceURL: workspaceURL,
-		spClientID:   "test-client-id",
-		expiryBuffer: 5 * time.Minute,
-		awsProvider:  provider,
-		httpClient:   &http.Client{Timeout: 5 * time.Second},
+		workspaceURL:  workspaceURL,
+		spClientID:    "test-client-id",
+		expiryBuffer:  5 * time.Minute,
+		subjectSource: provider,
+		httpClient:    &http.Client{Timeout: 5 * time.Second},
+		logger:        zap.NewNop(),
 	}
 }
 
@@ -74,7 +84,7 @@ func TestTokenCache_CacheHit(t *testing.T) {
 	server := createMockOIDCServer(t, "test-token-123", 3600)
 	defer server.Close()
 
-	mock := &mockAWSTokenProvider{token: "aws-token"}
+	mock := &mockSubjectTokenSource{token: "aws-token"}
 	cache := newTestTokenCache(server.URL, mock)
 
 	ctx := context.Background()
@@ -107,7 +117,7 @@ func TestTokenCache_CacheExpiry(t *testing.T) {
 	server := createMockOIDCServerWithCounter(t, "test-token", 3600, &counter)
 	defer server.Close()
 
-	mock := &mockAWSTokenProvider{token: "aws-token"}
+	mock := &mockSubjectTokenSource{token: "aws-token"}
 	cache := newTestTokenCache(server.URL, mock)
 
 	ctx := context.Background()
@@ -140,7 +150,7 @@ func TestTokenCache_ExpiredTokenNotReturned(t *testing.T) {
 	server := createMockOIDCServerWithCounter(t, "fresh-token", 3600, &counter)
 	defer server.Close()
 
-	mock := &mockAWSTokenProvider{token: "aws-token"}
+	mock := &mockSubjectTokenSource{token: "aws-token"}
 	cache := newTestTokenCache(server.URL, mock)
 
 	// Inject an already-expired token directly.
@@ -169,7 +179,7 @@ func TestTokenCache_ExpiresInZero(t *testing.T) {
 	server := createMockOIDCServer(t, "tok", 0 /* ExpiresIn */)
 	defer server.Close()
 
-	mock := &mockAWSTokenProvider{token: "aws-token"}
+	mock := &mockSubjectTokenSource{token: "aws-token"}
 	cache := newTestTokenCache(server.URL, mock)
 
 	before := time.Now()
@@ -194,7 +204,7 @@ func TestTokenCache_ConcurrentAccess(t *testing.T) {
 	server := createMockOIDCServerWithCounter(t, "concurrent-token", 3600, &counter)
 	defer server.Close()
 
-	mock := &mockAWSTokenProvider{token: "aws-token"}
+	mock := &mockSubjectTokenSource{token: "aws-token"}
 	cache := newTestTokenCache(server.URL, mock)
 
 	ctx := context.Background()
@@ -233,7 +243,7 @@ func TestTokenCache_AWSProviderError(t *testing.T) {
 	server := createMockOIDCServer(t, "tok", 3600)
 	defer server.Close()
 
-	mock := &mockAWSTokenProvider{err: fmt.Errorf("aws cred error")}
+	mock := &mockSubjectTokenSource{err: fmt.Errorf("aws cred error")}
 	cache := newTestTokenCache(server.URL, mock)
 
 	_, err := cache.GetToken(context.Background())
@@ -254,7 +264,7 @@ func TestTokenCache_OIDCReturns401(t *testing.T) {
 	}))
 	defer server.Close()
 
-	mock := &mockAWSTokenProvider{token: "aws-token"}
+	mock := &mockSubjectTokenSource{token: "aws-token"}
 	cache := newTestTokenCache(server.URL, mock)
 
 	_, err := cache.GetToken(context.Background())
@@ -270,7 +280,7 @@ func TestTokenCache_OIDCReturns429(t *testing.T) {
 	}))
 	defer server.Close()
 
-	mock := &mockAWSTokenProvider{token: "aws-token"}
+	mock := &mockSubjectTokenSource{token: "aws-token"}
 	cache := newTestTokenCache(server.URL, mock)
 
 	_, err := cache.GetToken(context.Background())
@@ -286,7 +296,7 @@ func TestTokenCache_ServerUnreachable(t *testing.T) {
 	url := server.URL
 	server.Close()
 
-	mock := &mockAWSTokenProvider{token: "aws-token"}
+	mock := &mockSubjectTokenSource{token: "aws-token"}
 	cache := newTestTokenCache(url, mock)
 
 	_, err := cache.GetToken(context.Background())
@@ -303,7 +313,7 @@ func TestTokenCache_MalformedJSONOnSuccess(t *testing.T) {
 	}))
 	defer server.Close()
 
-	mock := &mockAWSTokenProvider{token: "aws-token"}
+	mock := &mockSubjectTokenSource{token: "aws-token"}
 	cache := newTestTokenCache(server.URL, mock)
 
 	_, err := cache.GetToken(context.Background())
@@ -321,7 +331,7 @@ func TestTokenCache_MissingAccessToken(t *testing.T) {
 	}))
 	defer server.Close()
 
-	mock := &mockAWSTokenProvider{token: "aws-token"}
+	mock := &mockSubjectTokenSource{token: "aws-token"}
 	cache := newTestTokenCache(server.URL, mock)
 
 	_, err := cache.GetToken(context.Background())
@@ -329,3 +339,621 @@ func TestTokenCache_MissingAccessToken(t *testing.T) {
 		t.Fatal("expected error for missing access_token, got nil")
 	}
 }
+
+// TestTokenCache_ClientCredentials verifies M2M mode posts a client_credentials grant with basic auth and no AWS call.
+func TestTokenCache_ClientCredentials(t *testing.T) {
+	var gotGrant, gotUser, gotPass, gotSubject string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		gotGrant = r.PostForm.Get("grant_type")
+		gotSubject = r.PostForm.Get("subject_token")
+		gotUser, gotPass, _ = r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokenExchangeResponse{
+			AccessToken: "m2m-token",
+			TokenType:   "Bearer",
+			ExpiresIn:   3600,
+		})
+	}))
+	defer server.Close()
+
+	cache := newTestTokenCache(server.URL, nil)
+	cache.clientSecret = "test-secret"
+
+	token, err := cache.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if token != "m2m-token" {
+		t.Errorf("token = %q, want %q", token, "m2m-token")
+	}
+	if gotGrant != grantTypeClientCreds {
+		t.Errorf("grant_type = %q, want %q", gotGrant, grantTypeClientCreds)
+	}
+	if gotUser != "test-client-id" || gotPass != "test-secret" {
+		t.Errorf("basic auth = %q:%q, want test-client-id:test-secret", gotUser, gotPass)
+	}
+	if gotSubject != "" {
+		t.Errorf("subject_token = %q, want empty in M2M mode", gotSubject)
+	}
+}
+
+// TestTokenCache_RefreshForcesExchange verifies Refresh bypasses a still-valid cached token.
+func TestTokenCache_RefreshForcesExchange(t *testing.T) {
+	var counter atomic.Int32
+	server := createMockOIDCServerWithCounter(t, "fresh-token", 3600, &counter)
+	defer server.Close()
+
+	cache := newTestTokenCache(server.URL, &mockSubjectTokenSource{token: "aws-token"})
+	cache.cachedToken = "old-token"
+	cache.tokenExpiry = time.Now().Add(1 * time.Hour)
+
+	token, err := cache.Refresh(context.Background())
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if token != "fresh-token" {
+		t.Errorf("token = %q, want %q", token, "fresh-token")
+	}
+	if counter.Load() != 1 {
+		t.Errorf("expected 1 exchange, got %d", counter.Load())
+	}
+}
+
+// TestTokenCache_NextRefreshIn verifies the proactive refresh schedule.
+func TestTokenCache_NextRefreshIn(t *testing.T) {
+	cache := newTestTokenCache("https://adb-123.cloud.databricks.com", nil)
+
+	if got := cache.nextRefreshIn(); got != 0 {
+		t.Errorf("empty cache: nextRefreshIn = %v, want 0", got)
+	}
+
+	cache.cachedToken = "tok"
+	cache.tokenExpiry = time.Now().Add(1 * time.Hour)
+	got := cache.nextRefreshIn()
+	// Buffer is 5m with up to 1m jitter, so renewal lands between 54m and 55m from now.
+	if got > 55*time.Minute || got < 54*time.Minute-time.Second {
+		t.Errorf("nextRefreshIn = %v, want between 54m and 55m", got)
+	}
+
+	cache.tokenExpiry = time.Now().Add(1 * time.Minute)
+	if got := cache.nextRefreshIn(); got != minRefreshInterval {
+		t.Errorf("short-lived token: nextRefreshIn = %v, want %v", got, minRefreshInterval)
+	}
+}
+
+// TestTokenCache_RetriesTransientFailures verifies 429 (with Retry-After) and 5xx responses are retried.
+func TestTokenCache_RetriesTransientFailures(t *testing.T) {
+	var counter atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch counter.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tokenExchangeResponse{AccessToken: "after-retry", ExpiresIn: 3600})
+		}
+	}))
+	defer server.Close()
+
+	cache := newTestTokenCache(server.URL, &mockSubjectTokenSource{token: "aws-token"})
+	cache.retry = retryPolicy{maxAttempts: 3, initialInterval: time.Millisecond, maxInterval: 5 * time.Millisecond}
+
+	token, err := cache.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if token != "after-retry" {
+		t.Errorf("token = %q, want %q", token, "after-retry")
+	}
+	if counter.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", counter.Load())
+	}
+}
+
+// TestTokenCache_InvalidClientNotRetried verifies a 401 invalid_client is returned without retrying.
+func TestTokenCache_InvalidClientNotRetried(t *testing.T) {
+	var counter atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(tokenExchangeErrorResponse{Error: "invalid_client"})
+	}))
+	defer server.Close()
+
+	cache := newTestTokenCache(server.URL, &mockSubjectTokenSource{token: "aws-token"})
+	cache.retry = retryPolicy{maxAttempts: 3, initialInterval: time.Millisecond, maxInterval: 5 * time.Millisecond}
+
+	if _, err := cache.GetToken(context.Background()); err == nil {
+		t.Fatal("expected error for invalid_client, got nil")
+	}
+	if counter.Load() != 1 {
+		t.Errorf("expected 1 attempt, got %d", counter.Load())
+	}
+}
+
+// TestTokenCache_GraceModeServesStaleToken verifies a failed refresh inside expiry_buffer falls back
+// to the still-valid cached token and records the failure.
+func TestTokenCache_GraceModeServesStaleToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	mock := &mockSubjectTokenSource{token: "aws-token"}
+	cache := newTestTokenCache(server.URL, mock)
+	cache.graceMode = true
+	cache.cachedToken = "last-known-good"
+	cache.tokenExpiry = time.Now().Add(2 * time.Minute) // inside the 5m buffer, not yet expired
+	reader := newTestTelemetry(t, cache)
+
+	token, err := cache.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if token != "last-known-good" {
+		t.Errorf("token = %q, want %q", token, "last-known-good")
+	}
+
+	// A second call right after the failure serves the stale token without another exchange.
+	if _, err := cache.GetToken(context.Background()); err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if mock.callCount.Load() != 1 {
+		t.Errorf("expected 1 exchange attempt while failure is recent, got %d", mock.callCount.Load())
+	}
+
+	metrics := collectMetrics(t, reader)
+	if got := sumValue(t, metrics["otelcol_databricksauth_token_exchange_failures"], errorClassKey.String(errorClassServer)); got != 1 {
+		t.Errorf("exchange failures = %d, want 1", got)
+	}
+	if got := sumValue(t, metrics["otelcol_databricksauth_grace_tokens_served"]); got != 2 {
+		t.Errorf("grace tokens served = %d, want 2", got)
+	}
+}
+
+// TestTokenCache_GraceModeDoesNotServeExpiredToken verifies grace mode never returns a hard-expired token.
+func TestTokenCache_GraceModeDoesNotServeExpiredToken(t *testing.T) {
+	mock := &mockSubjectTokenSource{err: fmt.Errorf("aws down")}
+	cache := newTestTokenCache("https://adb-123.cloud.databricks.com", mock)
+	cache.graceMode = true
+	cache.cachedToken = "expired-token"
+	cache.tokenExpiry = time.Now().Add(-1 * time.Second)
+
+	if _, err := cache.GetToken(context.Background()); err == nil {
+		t.Fatal("expected error once the cached token has expired, got nil")
+	}
+}
+
+// TestTokenCache_WithoutGraceModeRefreshFailureErrors verifies the default behaviour is unchanged.
+func TestTokenCache_WithoutGraceModeRefreshFailureErrors(t *testing.T) {
+	mock := &mockSubjectTokenSource{err: fmt.Errorf("aws down")}
+	cache := newTestTokenCache("https://adb-123.cloud.databricks.com", mock)
+	cache.cachedToken = "still-valid"
+	cache.tokenExpiry = time.Now().Add(2 * time.Minute)
+
+	if _, err := cache.GetToken(context.Background()); err == nil {
+		t.Fatal("expected error without grace mode, got nil")
+	}
+}
+
+// TestTokenCache_LogsRefreshWithoutToken verifies successful refreshes are logged with context but no token value.
+func TestTokenCache_LogsRefreshWithoutToken(t *testing.T) {
+	server := createMockOIDCServer(t, "super-secret-token", 3600)
+	defer server.Close()
+
+	core, logs := observer.New(zap.DebugLevel)
+	cache := newTestTokenCache(server.URL, &mockSubjectTokenSource{token: "aws-secret-jwt"})
+	cache.logger = zap.New(core)
+
+	if _, err := cache.GetToken(context.Background()); err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+
+	entries := logs.FilterMessage("Refreshed Databricks token").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 refresh log entry, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["mode"] != "federation" || fields["client_id"] != "test-client-id" {
+		t.Errorf("unexpected log fields: %v", fields)
+	}
+	if host := strings.TrimPrefix(server.URL, "http://"); fields["workspace_host"] != host {
+		t.Errorf("workspace_host = %v, want %q", fields["workspace_host"], host)
+	}
+	if _, ok := fields["expiry"]; !ok {
+		t.Error("expected expiry field in refresh log")
+	}
+	for _, e := range logs.All() {
+		line := fmt.Sprintf("%s %v", e.Message, e.ContextMap())
+		if strings.Contains(line, "super-secret-token") || strings.Contains(line, "aws-secret-jwt") {
+			t.Errorf("log entry leaks a token: %s", line)
+		}
+	}
+}
+
+// TestTokenCache_LogsOAuthErrorRateLimited verifies refresh failures log the OAuth error code and are rate limited.
+func TestTokenCache_LogsOAuthErrorRateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(tokenExchangeErrorResponse{Error: "invalid_client", ErrorDescription: "bad credentials"})
+	}))
+	defer server.Close()
+
+	core, logs := observer.New(zap.DebugLevel)
+	cache := newTestTokenCache(server.URL, &mockSubjectTokenSource{token: "aws-token"})
+	cache.logger = newRateLimitedLogger(zap.New(core), time.Minute)
+
+	for range 5 {
+		if _, err := cache.GetToken(context.Background()); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	}
+
+	entries := logs.FilterMessage("Databricks token refresh failed").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected failures to be rate limited to 1 log entry, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["oauth_error"] != "invalid_client" {
+		t.Errorf("oauth_error = %v, want invalid_client", fields["oauth_error"])
+	}
+	if fields["status_code"] != int64(http.StatusUnauthorized) {
+		t.Errorf("status_code = %v, want 401", fields["status_code"])
+	}
+}
+
+// TestIsPermanentAuthError verifies only collector misconfiguration is permanent, and that failures
+// fixed in Databricks are told apart from transient ones.
+func TestIsPermanentAuthError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		want           bool
+		databricksSide bool
+	}{
+		{name: "invalid_client", err: &tokenEndpointError{StatusCode: http.StatusUnauthorized, Code: "invalid_client"}, want: true},
+		{name: "invalid_scope", err: &tokenEndpointError{StatusCode: http.StatusBadRequest, Code: "invalid_scope"}, want: true},
+		{name: "unauthorized_client", err: &tokenEndpointError{StatusCode: http.StatusBadRequest, Code: "unauthorized_client"}, databricksSide: true},
+		{name: "federation policy mismatch", err: &tokenEndpointError{StatusCode: http.StatusBadRequest, Code: "invalid_request", Description: "no matching Federation Policy"}, databricksSide: true},
+		{name: "other 400", err: &tokenEndpointError{StatusCode: http.StatusBadRequest, Code: "invalid_request"}},
+		{name: "503", err: &retryableError{err: &tokenEndpointError{StatusCode: http.StatusServiceUnavailable}}},
+		{name: "AWS failure", err: fmt.Errorf("failed to get AWS token: boom")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPermanentAuthError(tt.err); got != tt.want {
+				t.Errorf("isPermanentAuthError() = %v, want %v", got, tt.want)
+			}
+			if got := databricksSideAuthError(tt.err) != nil; got != tt.databricksSide {
+				t.Errorf("databricksSideAuthError() != nil = %v, want %v", got, tt.databricksSide)
+			}
+		})
+	}
+}
+
+// TestTokenCache_InvalidateKeepsNewerToken verifies invalidate only drops the token that was rejected.
+func TestTokenCache_InvalidateKeepsNewerToken(t *testing.T) {
+	cache := newTestTokenCache("https://adb-123.cloud.databricks.com", nil)
+	cache.cachedToken = "newer-token"
+	cache.tokenExpiry = time.Now().Add(1 * time.Hour)
+
+	cache.invalidate("revoked-token")
+	if cache.cachedToken != "newer-token" {
+		t.Errorf("cachedToken = %q, want newer token kept", cache.cachedToken)
+	}
+	if !cache.invalidate("newer-token") || cache.cachedToken != "" {
+		t.Errorf("cachedToken = %q, want cleared", cache.cachedToken)
+	}
+
+	// A second forced refresh within forcedRefreshWindow is refused.
+	cache.cachedToken = "next-token"
+	cache.tokenExpiry = time.Now().Add(1 * time.Hour)
+	if cache.invalidate("next-token") || cache.cachedToken != "next-token" {
+		t.Errorf("cachedToken = %q, want kept within forcedRefreshWindow", cache.cachedToken)
+	}
+}
+
+// fakeSTSServer answers GetWebIdentityToken with a canned token and records the request form.
+func fakeSTSServer(t *testing.T, token string, gotForm *url.Values) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*gotForm = r.PostForm
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<GetWebIdentityTokenResponse><GetWebIdentityTokenResult>`+
+			`<WebIdentityToken>%s</WebIdentityToken><Expiration>%s</Expiration>`+
+			`</GetWebIdentityTokenResult></GetWebIdentityTokenResponse>`,
+			token, time.Now().Add(5*time.Minute).UTC().Format(time.RFC3339))
+	}))
+}
+
+// TestSTSTokenProvider_Options verifies endpoint, region, audience and signing algorithm are passed to STS.
+func TestSTSTokenProvider_Options(t *testing.T) {
+	var gotForm url.Values
+	server := fakeSTSServer(t, "aws-jwt", &gotForm)
+	defer server.Close()
+
+	awsCfg := AWSConfig{
+		Region:           "eu-west-1",
+		STSEndpoint:      server.URL,
+		Audience:         []string{"https://accounts.cloud.databricks.com", "AwsTokenExchange"},
+		SigningAlgorithm: "ES384",
+	}
+	loadOpts := append(awsCfg.loadOptions(),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("AKID", "SECRET", "")))
+	provider, err := NewSTSTokenProvider(context.Background(), nil, awsCfg.stsOptions(), loadOpts...)
+	if err != nil {
+		t.Fatalf("NewSTSTokenProvider: %v", err)
+	}
+	if region := provider.stsClient.Options().Region; region != "eu-west-1" {
+		t.Errorf("region = %q, want eu-west-1", region)
+	}
+
+	token, err := provider.GetWebIdentityToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetWebIdentityToken: %v", err)
+	}
+	if token != "aws-jwt" {
+		t.Errorf("token = %q, want %q", token, "aws-jwt")
+	}
+	if gotForm.Get("Action") != "GetWebIdentityToken" {
+		t.Errorf("Action = %q, want GetWebIdentityToken", gotForm.Get("Action"))
+	}
+	if gotForm.Get("SigningAlgorithm") != "ES384" {
+		t.Errorf("SigningAlgorithm = %q, want ES384", gotForm.Get("SigningAlgorithm"))
+	}
+	if gotForm.Get("Audience.member.1") != "https://accounts.cloud.databricks.com" || gotForm.Get("Audience.member.2") != "AwsTokenExchange" {
+		t.Errorf("Audience = %v, want both configured audiences", gotForm)
+	}
+}
+
+// TestSTSTokenProvider_Defaults verifies the default audience and signing algorithm.
+func TestSTSTokenProvider_Defaults(t *testing.T) {
+	var gotForm url.Values
+	server := fakeSTSServer(t, "aws-jwt", &gotForm)
+	defer server.Close()
+
+	provider, err := NewSTSTokenProvider(context.Background(), nil, STSTokenProviderOptions{Endpoint: server.URL},
+		awsconfig.WithRegion("us-east-1"),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("AKID", "SECRET", "")))
+	if err != nil {
+		t.Fatalf("NewSTSTokenProvider: %v", err)
+	}
+	if _, err := provider.GetWebIdentityToken(context.Background()); err != nil {
+		t.Fatalf("GetWebIdentityToken: %v", err)
+	}
+	if gotForm.Get("SigningAlgorithm") != defaultSTSSigningAlg || gotForm.Get("Audience.member.1") != defaultSTSAudience {
+		t.Errorf("form = %v, want default audience and signing algorithm", gotForm)
+	}
+}
+
+// TestSTSTokenProvider_RetriesThrottling verifies STS throttling is retried with the configured
+// retry policy, including initial_interval, rather than the SDK's default backoff.
+func TestSTSTokenProvider_RetriesThrottling(t *testing.T) {
+	var gotForm url.Values
+	var hits atomic.Int32
+	sts := fakeSTSServer(t, "aws-jwt", &gotForm)
+	defer sts.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= 2 {
+			w.Header().Set("Content-Type", "text/xml")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>Throttling</Code>`+
+				`<Message>Rate exceeded</Message></Error><RequestId>req-1</RequestId></ErrorResponse>`)
+			return
+		}
+		sts.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	newProvider := func(policy retryPolicy) *STSTokenProvider {
+		provider, err := NewSTSTokenProvider(context.Background(), nil, STSTokenProviderOptions{Endpoint: server.URL},
+			awsconfig.WithRegion("us-east-1"),
+			awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("AKID", "SECRET", "")),
+			awsconfig.WithRetryer(func() aws.Retryer { return stsRetryer(policy) }))
+		if err != nil {
+			t.Fatalf("NewSTSTokenProvider: %v", err)
+		}
+		return provider
+	}
+
+	start := time.Now()
+	token, err := newProvider(retryPolicy{maxAttempts: 3, initialInterval: 100 * time.Millisecond, maxInterval: 200 * time.Millisecond}).
+		GetWebIdentityToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetWebIdentityToken: %v", err)
+	}
+	elapsed := time.Since(start)
+	if token != "aws-jwt" || hits.Load() != 3 {
+		t.Errorf("token = %q after %d requests, want aws-jwt after 3", token, hits.Load())
+	}
+	// Equal jitter keeps each delay within [interval/2, interval): 50ms+100ms to 100ms+200ms.
+	if elapsed < 150*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("retries took %v, want the retry policy's backoff (150ms-300ms)", elapsed)
+	}
+
+	hits.Store(0)
+	provider := newProvider(retryPolicy{maxAttempts: 2, initialInterval: 10 * time.Millisecond, maxInterval: 20 * time.Millisecond})
+	if _, err := provider.GetWebIdentityToken(context.Background()); err == nil || hits.Load() != 2 {
+		t.Errorf("err = %v after %d requests, want throttling error after max_attempts (2)", err, hits.Load())
+	}
+}
+
+// TestSTSTokenProvider_AssumeRole verifies the assumed role's credentials sign GetWebIdentityToken.
+func TestSTSTokenProvider_AssumeRole(t *testing.T) {
+	var assumeForm url.Values
+	var webIdentityAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		expiration := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		switch r.PostForm.Get("Action") {
+		case "AssumeRole":
+			assumeForm = r.PostForm
+			fmt.Fprintf(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials>`+
+				`<AccessKeyId>ASIAASSUMED</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>`+
+				`<SessionToken>session</SessionToken><Expiration>%s</Expiration></Credentials>`+
+				`<AssumedRoleUser><Arn>arn:aws:sts::123456789012:assumed-role/otel-databricks/collector</Arn>`+
+				`<AssumedRoleId>AROA:collector</AssumedRoleId></AssumedRoleUser>`+
+				`</AssumeRoleResult></AssumeRoleResponse>`, expiration)
+		case "GetWebIdentityToken":
+			webIdentityAuth = r.Header.Get("Authorization")
+			fmt.Fprintf(w, `<GetWebIdentityTokenResponse><GetWebIdentityTokenResult>`+
+				`<WebIdentityToken>aws-jwt</WebIdentityToken><Expiration>%s</Expiration>`+
+				`</GetWebIdentityTokenResult></GetWebIdentityTokenResponse>`, expiration)
+		default:
+			http.Error(w, "unexpected action", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	awsCfg := AWSConfig{
+		Region:        "us-east-1",
+		STSEndpoint:   server.URL,
+		AssumeRoleARN: "arn:aws:iam::123456789012:role/otel-databricks",
+		ExternalID:    "ext-123",
+		SessionName:   "collector",
+		Duration:      time.Hour,
+	}
+	loadOpts := append(awsCfg.loadOptions(),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("AKID", "SECRET", "")))
+	provider, err := NewSTSTokenProvider(context.Background(), nil, awsCfg.stsOptions(), loadOpts...)
+	if err != nil {
+		t.Fatalf("NewSTSTokenProvider: %v", err)
+	}
+	if _, err := provider.GetWebIdentityToken(context.Background()); err != nil {
+		t.Fatalf("GetWebIdentityToken: %v", err)
+	}
+
+	if assumeForm.Get("RoleArn") != awsCfg.AssumeRoleARN {
+		t.Errorf("RoleArn = %q, want %q", assumeForm.Get("RoleArn"), awsCfg.AssumeRoleARN)
+	}
+	if assumeForm.Get("ExternalId") != "ext-123" || assumeForm.Get("RoleSessionName") != "collector" || assumeForm.Get("DurationSeconds") != "3600" {
+		t.Errorf("AssumeRole form = %v, want external id, session name and duration", assumeForm)
+	}
+	if !strings.Contains(webIdentityAuth, "Credential=ASIAASSUMED/") {
+		t.Errorf("GetWebIdentityToken Authorization = %q, want signature by assumed role credentials", webIdentityAuth)
+	}
+}
+
+// TestTokenCache_AccountEndpoint verifies the exchange is posted to the account-level token endpoint.
+func TestTokenCache_AccountEndpoint(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokenExchangeResponse{AccessToken: "account-token", TokenType: "Bearer", ExpiresIn: 3600})
+	}))
+	defer server.Close()
+
+	cfg := &Config{WorkspaceURL: "https://adb-123.cloud.databricks.com", AccountID: "0d26daa6-5e44-4c97-a497-ef015f91254a", AccountHost: server.URL}
+	cache := newTestTokenCache(cfg.WorkspaceURL, &mockSubjectTokenSource{token: "aws-token"})
+	cache.tokenURL = cfg.tokenEndpoint()
+
+	token, err := cache.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if token != "account-token" {
+		t.Errorf("token = %q, want account-token", token)
+	}
+	if want := "/oidc/accounts/0d26daa6-5e44-4c97-a497-ef015f91254a/v1/token"; gotPath != want {
+		t.Errorf("path = %q, want %q", gotPath, want)
+	}
+}
+
+// formCapturingServer records the form body of the last token request.
+func formCapturingServer(t *testing.T, gotForm *url.Values) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*gotForm = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokenExchangeResponse{AccessToken: "tok", TokenType: "Bearer", ExpiresIn: 3600})
+	}))
+}
+
+// TestTokenCache_RequestParams verifies the form body for default and configured token request parameters.
+func TestTokenCache_RequestParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Config
+		secret string
+		want   url.Values
+	}{
+		{
+			name: "federation defaults",
+			cfg:  Config{},
+			want: url.Values{
+				"grant_type":         {grantTypeTokenExchange},
+				"subject_token":      {"aws-token"},
+				"subject_token_type": {tokenTypeJWT},
+				"client_id":          {"test-client-id"},
+				"scope":              {"all-apis"},
+			},
+		},
+		{
+			name: "federation with exchange parameters",
+			cfg: Config{
+				Scopes:             []string{"sql", "offline_access"},
+				Audience:           "https://adb-123.cloud.databricks.com",
+				Resource:           "https://adb-123.cloud.databricks.com/api",
+				RequestedTokenType: "urn:ietf:params:oauth:token-type:access_token",
+			},
+			want: url.Values{
+				"grant_type":           {grantTypeTokenExchange},
+				"subject_token":        {"aws-token"},
+				"subject_token_type":   {tokenTypeJWT},
+				"client_id":            {"test-client-id"},
+				"scope":                {"sql offline_access"},
+				"audience":             {"https://adb-123.cloud.databricks.com"},
+				"resource":             {"https://adb-123.cloud.databricks.com/api"},
+				"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+			},
+		},
+		{
+			name:   "m2m with scopes",
+			cfg:    Config{Scopes: []string{"sql"}},
+			secret: "test-secret",
+			want: url.Values{
+				"grant_type": {grantTypeClientCreds},
+				"scope":      {"sql"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotForm url.Values
+			server := formCapturingServer(t, &gotForm)
+			defer server.Close()
+
+			cache := newTestTokenCache(server.URL, &mockSubjectTokenSource{token: "aws-token"})
+			cache.clientSecret = configopaque.String(tt.secret)
+			cache.requestParams = tt.cfg.requestParams()
+			if _, err := cache.GetToken(context.Background()); err != nil {
+				t.Fatalf("GetToken: %v", err)
+			}
+			if gotForm.Encode() != tt.want.Encode() {
+				t.Errorf("form = %s\nwant   %s", gotForm.Encode(), tt.want.Encode())
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package databricksauthextension
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Databricks unified auth environment variables, as honored by the Databricks CLI and SDKs.
+const (
+	envHost          = "DATABRICKS_HOST"
+	envClientID      = "DATABRICKS_CLIENT_ID"
+	envToken         = "DATABRICKS_TOKEN"         // #nosec G101 -- environment variable name
+	envClientSecret  = "DATABRICKS_CLIENT_SECRET" // #nosec G101 -- environment variable name
+	envConfigFile    = "DATABRICKS_CONFIG_FILE"
+	envConfigProfile = "DATABRICKS_CONFIG_PROFILE"
+
+	defaultConfigFile = "~/.databrickscfg"
+	defaultProfile    = "DEFAULT"
+	sourceYAML        = "config"
+)
+
+// configSources records which source supplied each resolved field: "config", an environment
+// variable name, or a .databrickscfg profile.
+type configSources map[string]string
+
+// String lists the fields not supplied by the collector config, e.g.
+// "token from DATABRICKS_TOKEN, workspace_url from profile DEFAULT in ~/.databrickscfg".
+func (s configSources) String() string {
+	var parts []string
+	for _, field := range slices.Sorted(maps.Keys(s)) {
+		if src := s[field]; src != sourceYAML {
+			parts = append(parts, field+" from "+src)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// databricksProfile is a .databrickscfg section; source describes it in configSources.
+type databricksProfile struct {
+	source string
+	values map[string]string
+}
+
+// resolve returns a copy of c with workspace_url, token, sp_client_id and client_secret filled in
+// from the Databricks unified auth environment variables and .databrickscfg profile, with the SDK
+// precedence: collector config, then environment, then profile.
+//
+// Credentials are only filled in when the collector config sets none of token, token_file,
+// sp_client_id and client_secret, so an exported DATABRICKS_CLIENT_SECRET or a DEFAULT profile
+// token cannot switch the auth mode the config chose. workspace_url is resolved on its own, but
+// the implicit DEFAULT profile's host is only used alongside credentials from outside the config;
+// otherwise it would become the allowed_hosts default of a plain static-token config.
+//
+// The default profile is optional: if it cannot be parsed, it is only an error when the
+// collector config and environment alone do not form a valid configuration.
+func (c *Config) resolve() (*Config, configSources, error) {
+	profile, profileErr := c.loadProfile()
+	_, explicit := c.profileName()
+	if profileErr != nil && explicit {
+		return nil, nil, profileErr
+	}
+
+	resolved := *c
+	origin := configSources{}
+	credentials := []struct {
+		field, env, key string
+		dst             *string
+	}{
+		{"token", envToken, "token", (*string)(&resolved.Token)},
+		{"sp_client_id", envClientID, "client_id", &resolved.SPClientID},
+		{"client_secret", envClientSecret, "client_secret", (*string)(&resolved.ClientSecret)},
+	}
+	borrowed := false
+	for _, attr := range credentials {
+		switch {
+		case c.hasCredentials():
+			if *attr.dst != "" {
+				origin[attr.field] = sourceYAML
+			}
+		case os.Getenv(attr.env) != "":
+			*attr.dst, origin[attr.field], borrowed = os.Getenv(attr.env), attr.env, true
+		case profile.values[attr.key] != "":
+			*attr.dst, origin[attr.field], borrowed = profile.values[attr.key], profile.source, true
+		}
+	}
+
+	switch {
+	case c.WorkspaceURL != "":
+		origin["workspace_url"] = sourceYAML
+	case os.Getenv(envHost) != "":
+		resolved.WorkspaceURL, origin["workspace_url"] = normalizeHost(os.Getenv(envHost)), envHost
+	case profile.values["host"] != "" && (explicit || borrowed):
+		resolved.WorkspaceURL, origin["workspace_url"] = normalizeHost(profile.values["host"]), profile.source
+	}
+
+	if profileErr != nil && resolved.validate() != nil {
+		return nil, nil, profileErr
+	}
+	return &resolved, origin, nil
+}
+
+// hasCredentials reports whether the collector config sets any credential setting.
+func (c *Config) hasCredentials() bool {
+	return c.Token != "" || c.TokenFile != "" || c.SPClientID != "" || c.ClientSecret != ""
+}
+
+// normalizeHost adds the https scheme the SDKs assume for a bare DATABRICKS_HOST or profile host;
+// workspace_url from the collector config is validated as written.
+func normalizeHost(host string) string {
+	if strings.Contains(host, "://") {
+		return host
+	}
+	return "https://" + host
+}
+
+// profileName returns the selected .databrickscfg profile: the profile option, then
+// DATABRICKS_CONFIG_PROFILE, then DEFAULT. explicit is false for the DEFAULT fallback.
+func (c *Config) profileName() (name string, explicit bool) {
+	if c.Profile != "" {
+		return c.Profile, true
+	}
+	if name := os.Getenv(envConfigProfile); name != "" {
+		return name, true
+	}
+	return defaultProfile, false
+}
+
+// loadProfile reads the selected profile from DATABRICKS_CONFIG_FILE or ~/.databrickscfg.
+// A missing file or DEFAULT profile yields no values; an explicitly selected one is an error.
+func (c *Config) loadProfile() (databricksProfile, error) {
+	name, explicit := c.profileName()
+	path := os.Getenv(envConfigFile)
+	if path == "" {
+		path = defaultConfigFile
+	}
+	display := path
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			if explicit {
+				return databricksProfile{}, fmt.Errorf("profile %q: cannot locate home directory: %w", name, err)
+			}
+			return databricksProfile{}, nil
+		}
+		path = filepath.Join(home, rest)
+	}
+
+	sections, err := parseDatabricksCfg(path)
+	if err != nil {
+		if !explicit && os.IsNotExist(err) {
+			return databricksProfile{}, nil
+		}
+		return databricksProfile{}, fmt.Errorf("profile %q: %w", name, err)
+	}
+	section, ok := sections[name]
+	if !ok {
+		if explicit {
+			return databricksProfile{}, fmt.Errorf("profile %q not found in %s", name, display)
+		}
+		return databricksProfile{}, nil
+	}
+	return databricksProfile{source: fmt.Sprintf("profile %s in %s", name, display), values: section}, nil
+}
+
+// parseDatabricksCfg parses the INI subset .databrickscfg uses: [section] headers, key = value
+// pairs and ; or # comments.
+func parseDatabricksCfg(path string) (map[string]map[string]string, error) {
+	f, err := os.Open(path) // #nosec G304 -- operator-selected config file
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sections := map[string]map[string]string{}
+	var current map[string]string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "" || strings.HasPrefix(text, ";") || strings.HasPrefix(text, "#"):
+		case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+			name := strings.TrimSpace(text[1 : len(text)-1])
+			if sections[name] == nil {
+				sections[name] = map[string]string{}
+			}
+			current = sections[name]
+		default:
+			key, value, ok := strings.Cut(text, "=")
+			if !ok || current == nil {
+				return nil, fmt.Errorf("%s:%d: expected [section] or key = value", path, line)
+			}
+			current[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return sections, nil
+}
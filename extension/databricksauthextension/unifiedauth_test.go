@@ -0,0 +1,228 @@
+package databricksauthextension
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain isolates the package tests from DATABRICKS_* variables and ~/.databrickscfg on the
+// machine running them, which resolve would otherwise merge into every Config under test.
+func TestMain(m *testing.M) {
+	for _, env := range []string{envHost, envClientID, envToken, envClientSecret, envConfigProfile} {
+		os.Unsetenv(env)
+	}
+	os.Setenv(envConfigFile, filepath.Join(os.TempDir(), "databricksauth-test-no-such-databrickscfg"))
+	os.Exit(m.Run())
+}
+
+const testDatabricksCfg = `; written by databricks auth login
+[DEFAULT]
+host  = adb-111.cloud.databricks.com
+token = dapi-default
+
+[staging]
+host          = https://adb-222.cloud.databricks.com
+client_id     = staging-sp
+client_secret = staging-secret
+`
+
+func writeDatabricksCfg(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".databrickscfg")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envConfigFile, path)
+}
+
+// TestConfig_resolve verifies the config > environment > profile precedence, applied to each
+// attribute independently.
+func TestConfig_resolve(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfgFile     string // default: testDatabricksCfg
+		cfg         Config
+		env         map[string]string
+		wantHost    string
+		wantToken   string
+		wantClient  string
+		wantSecret  string
+		wantSources string
+	}{
+		{
+			name:        "default profile",
+			wantHost:    "https://adb-111.cloud.databricks.com",
+			wantToken:   "dapi-default",
+			wantSources: "token from profile DEFAULT in $CFG, workspace_url from profile DEFAULT in $CFG",
+		},
+		{
+			name:        "profile option",
+			cfg:         Config{Profile: "staging"},
+			wantHost:    "https://adb-222.cloud.databricks.com",
+			wantClient:  "staging-sp",
+			wantSecret:  "staging-secret",
+			wantSources: "client_secret from profile staging in $CFG, sp_client_id from profile staging in $CFG, workspace_url from profile staging in $CFG",
+		},
+		{
+			name:        "DATABRICKS_CONFIG_PROFILE",
+			env:         map[string]string{envConfigProfile: "staging"},
+			wantHost:    "https://adb-222.cloud.databricks.com",
+			wantClient:  "staging-sp",
+			wantSecret:  "staging-secret",
+			wantSources: "client_secret from profile staging in $CFG, sp_client_id from profile staging in $CFG, workspace_url from profile staging in $CFG",
+		},
+		{
+			name:        "environment overrides profile",
+			env:         map[string]string{envHost: "adb-333.cloud.databricks.com", envToken: "dapi-env"},
+			wantHost:    "https://adb-333.cloud.databricks.com",
+			wantToken:   "dapi-env",
+			wantSources: "token from DATABRICKS_TOKEN, workspace_url from DATABRICKS_HOST",
+		},
+		{
+			name:       "config overrides environment",
+			cfgFile:    "[DEFAULT]\nhost = adb-111.cloud.databricks.com\n",
+			cfg:        Config{WorkspaceURL: "https://adb-444.cloud.databricks.com", SPClientID: "config-sp"},
+			env:        map[string]string{envHost: "adb-333.cloud.databricks.com", envClientID: "env-sp"},
+			wantHost:   "https://adb-444.cloud.databricks.com",
+			wantClient: "config-sp",
+		},
+		{
+			name:        "attributes combine across sources",
+			cfg:         Config{Profile: "staging"},
+			env:         map[string]string{envClientID: "env-sp"},
+			wantHost:    "https://adb-222.cloud.databricks.com",
+			wantClient:  "env-sp",
+			wantSecret:  "staging-secret",
+			wantSources: "client_secret from profile staging in $CFG, sp_client_id from DATABRICKS_CLIENT_ID, workspace_url from profile staging in $CFG",
+		},
+		{
+			name:        "token_file counts as config credentials",
+			cfg:         Config{TokenFile: "/var/run/secrets/databricks/token"},
+			env:         map[string]string{envHost: "adb-333.cloud.databricks.com", envToken: "dapi-env"},
+			wantHost:    "https://adb-333.cloud.databricks.com",
+			wantSources: "workspace_url from DATABRICKS_HOST",
+		},
+		{
+			name:       "DEFAULT profile token ignored with configured sp_client_id",
+			cfg:        Config{WorkspaceURL: "https://adb-444.cloud.databricks.com", SPClientID: "config-sp"},
+			wantHost:   "https://adb-444.cloud.databricks.com",
+			wantClient: "config-sp",
+		},
+		{
+			name:       "DATABRICKS_CLIENT_SECRET ignored with configured federation",
+			cfg:        Config{WorkspaceURL: "https://adb-444.cloud.databricks.com", SPClientID: "config-sp"},
+			env:        map[string]string{envClientSecret: "env-secret"},
+			wantHost:   "https://adb-444.cloud.databricks.com",
+			wantClient: "config-sp",
+		},
+		{
+			name:      "DEFAULT profile host ignored with configured token",
+			cfg:       Config{Token: "dapi-config", AllowedHosts: []string{"otlp.example.com"}},
+			wantToken: "dapi-config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := tt.cfgFile
+			if content == "" {
+				content = testDatabricksCfg
+			}
+			writeDatabricksCfg(t, content)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			got, sources, err := tt.cfg.resolve()
+			if err != nil {
+				t.Fatalf("resolve() error = %v", err)
+			}
+			if err := got.validate(); err != nil {
+				t.Errorf("validate() error = %v", err)
+			}
+			if got.WorkspaceURL != tt.wantHost {
+				t.Errorf("WorkspaceURL = %q, want %q", got.WorkspaceURL, tt.wantHost)
+			}
+			if string(got.Token) != tt.wantToken {
+				t.Errorf("Token = %q, want %q", got.Token, tt.wantToken)
+			}
+			if got.SPClientID != tt.wantClient {
+				t.Errorf("SPClientID = %q, want %q", got.SPClientID, tt.wantClient)
+			}
+			if string(got.ClientSecret) != tt.wantSecret {
+				t.Errorf("ClientSecret = %q, want %q", got.ClientSecret, tt.wantSecret)
+			}
+			want := strings.ReplaceAll(tt.wantSources, "$CFG", os.Getenv(envConfigFile))
+			if s := sources.String(); s != want {
+				t.Errorf("sources = %q, want %q", s, want)
+			}
+		})
+	}
+}
+
+// TestConfig_resolve_Profiles verifies a missing file or DEFAULT profile is ignored but an
+// explicitly selected profile must exist, and that a malformed file only fails when it is needed.
+func TestConfig_resolve_Profiles(t *testing.T) {
+	t.Run("missing file without profile", func(t *testing.T) {
+		if _, _, err := (&Config{}).resolve(); err != nil {
+			t.Errorf("resolve() error = %v", err)
+		}
+	})
+	t.Run("missing file with profile", func(t *testing.T) {
+		if _, _, err := (&Config{Profile: "staging"}).resolve(); err == nil {
+			t.Error("expected error for profile in missing file")
+		}
+	})
+	t.Run("unknown profile", func(t *testing.T) {
+		writeDatabricksCfg(t, testDatabricksCfg)
+		t.Setenv(envConfigProfile, "prod")
+		_, _, err := (&Config{}).resolve()
+		if err == nil || !strings.Contains(err.Error(), `profile "prod" not found`) {
+			t.Errorf("resolve() error = %v, want profile not found", err)
+		}
+	})
+	t.Run("no DEFAULT profile", func(t *testing.T) {
+		writeDatabricksCfg(t, "[staging]\nhost = adb-222.cloud.databricks.com\n")
+		if _, _, err := (&Config{}).resolve(); err != nil {
+			t.Errorf("resolve() error = %v", err)
+		}
+	})
+	t.Run("malformed file with profile", func(t *testing.T) {
+		writeDatabricksCfg(t, "host = adb-111.cloud.databricks.com\n")
+		if _, _, err := (&Config{Profile: "DEFAULT"}).resolve(); err == nil {
+			t.Error("expected error for key outside a section")
+		}
+	})
+	t.Run("malformed file not needed", func(t *testing.T) {
+		writeDatabricksCfg(t, "host = adb-111.cloud.databricks.com\n")
+		cfg := &Config{SPClientID: "client-id", WorkspaceURL: "https://adb-123.cloud.databricks.com"}
+		if _, _, err := cfg.resolve(); err != nil {
+			t.Errorf("resolve() error = %v, want malformed default profile ignored", err)
+		}
+	})
+	t.Run("malformed file needed", func(t *testing.T) {
+		writeDatabricksCfg(t, "host = adb-111.cloud.databricks.com\n")
+		_, _, err := (&Config{SPClientID: "client-id"}).resolve()
+		if err == nil || !strings.Contains(err.Error(), "expected [section]") {
+			t.Errorf("resolve() error = %v, want parse error", err)
+		}
+	})
+}
+
+// TestConfig_Validate_ReportsSources verifies a validation error names where the offending values came from.
+func TestConfig_Validate_ReportsSources(t *testing.T) {
+	t.Setenv(envHost, "adb-333.cloud.databricks.com")
+	t.Setenv(envToken, "dapi-env")
+	t.Setenv(envClientSecret, "env-secret")
+
+	err := (&Config{}).Validate()
+	if err == nil {
+		t.Fatal("expected error for token with client_secret")
+	}
+	for _, want := range []string{"token from DATABRICKS_TOKEN", "client_secret from DATABRICKS_CLIENT_SECRET"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}